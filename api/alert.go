@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
+	prommodel "github.com/prometheus/common/model"
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
 	"github.com/zionwu/monitoring-manager/model"
 	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/sync"
 	"github.com/zionwu/monitoring-manager/util"
 )
 
@@ -47,18 +51,24 @@ func (s *Server) createAlert(rw http.ResponseWriter, req *http.Request) (errCode
 	}
 
 	if err = s.checkAlertParam(alert); err != nil {
-		return http.StatusBadRequest, err
+		return paramErrorCode(err), err
 	}
 
-	_, err = service.GetRecipient(alert.RecipientID)
-	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("unable to find the recipient: %v", err)
+	for _, recipientID := range alert.RecipientIDs {
+		if _, err = service.GetRecipient(recipientID); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("unable to find the recipient: %v", err)
+		}
+	}
+
+	if err = checkAlertDependencies(alert); err != nil {
+		return http.StatusBadRequest, err
 	}
 
 	err = service.CreateAlert(alert)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	s.audit(req, "create", "alert", alert.Id, alert.Environment, nil, alert)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -93,14 +103,29 @@ func (s *Server) deleteAlert(rw http.ResponseWriter, req *http.Request) (errCode
 
 	alert, err := service.GetAlert(id)
 	if err != nil {
-		logrus.Errorf("Error while getting alert", err)
+		logrus.Errorf("Error while getting alert: %v", err)
 		return http.StatusBadRequest, err
 	}
 
+	//check if any alert depends on the alert
+	alertList, err := service.ListAlert(alert.Environment)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, a := range alertList {
+		if contains(a.DependsOn, alert.Id) {
+			return http.StatusBadRequest, fmt.Errorf("The alert %s is still a dependency of alert %s", id, a.Id)
+		}
+	}
+
 	err = service.DeleteAlert(id)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	if err = service.DeleteAlertStatus(id); err != nil {
+		logrus.Errorf("Error while deleting the status of alert %s: %v", id, err)
+	}
+	s.audit(req, "delete", "alert", alert.Id, alert.Environment, alert, nil)
 
 	apiContext.Write(toAlertResource(apiContext, alert))
 	return http.StatusOK, nil
@@ -123,20 +148,38 @@ func (s *Server) updateAlert(rw http.ResponseWriter, req *http.Request) (errCode
 		return http.StatusNotFound, err
 	}
 
+	//clients only aware of recipientId change it and send back the
+	//recipientIds they got, which it replaces
+	if alert.RecipientID != "" && alert.RecipientID != oriAlert.RecipientID && !contains(alert.RecipientIDs, alert.RecipientID) {
+		alert.RecipientIDs = []string{alert.RecipientID}
+	}
+
 	if err = s.checkAlertParam(alert); err != nil {
-		return http.StatusBadRequest, err
+		return paramErrorCode(err), err
 	}
 
-	_, err = service.GetRecipient(alert.RecipientID)
-	if err != nil {
-		return http.StatusBadRequest, fmt.Errorf("unable to find the recipient: %v", err)
+	for _, recipientID := range alert.RecipientIDs {
+		if _, err = service.GetRecipient(recipientID); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("unable to find the recipient: %v", err)
+		}
+	}
+
+	if err = checkAlertDependencies(alert); err != nil {
+		return http.StatusBadRequest, err
 	}
 
 	alert.State = oriAlert.State
+	alert.Flapping = oriAlert.Flapping
+	alert.Silence = oriAlert.Silence
+	//clients not aware of resource versions update the latest one
+	if alert.ResourceVersion == 0 {
+		alert.ResourceVersion = oriAlert.ResourceVersion
+	}
 	err = service.UpdateAlert(alert)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errorCode(err), err
 	}
+	s.audit(req, "update", "alert", alert.Id, alert.Environment, oriAlert, alert)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -163,11 +206,13 @@ func (s *Server) deactivateAlert(rw http.ResponseWriter, req *http.Request) (err
 		return http.StatusBadRequest, fmt.Errorf("Current state is not enabled, can not perform disable action")
 	}
 
+	before := *alert
 	alert.State = model.AlertStateDisabled
 	err = service.UpdateAlert(alert)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errorCode(err), err
 	}
+	s.audit(req, "disable", "alert", alert.Id, alert.Environment, &before, alert)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -194,11 +239,13 @@ func (s *Server) activateAlert(rw http.ResponseWriter, req *http.Request) (errCo
 		return http.StatusBadRequest, fmt.Errorf("Current state is not disabled, can not perform enable action")
 	}
 
+	before := *alert
 	alert.State = model.AlertStateEnabled
 	err = service.UpdateAlert(alert)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errorCode(err), err
 	}
+	s.audit(req, "enable", "alert", alert.Id, alert.Environment, &before, alert)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -226,15 +273,30 @@ func (s *Server) silenceAlert(rw http.ResponseWriter, req *http.Request) (errCod
 		return http.StatusBadRequest, fmt.Errorf("Current state is not active, can not perform slience action")
 	}
 
-	err = util.AddSilence(alert)
+	silence, err := getSilence(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = util.AddSilence(alert, silence)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Error while adding silence to AlertManager: %v", err)
 	}
 
+	before := *alert
 	alert.State = model.AlertStateSuppressed
+	alert.Silence = *silence
 	err = service.UpdateAlert(alert)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		//the alert was not silenced, so its silence must not be left behind
+		if err := util.DeleteSilence(silence.SilenceID); err != nil {
+			logrus.Errorf("Error while deleting silence %s of alert %s: %v", silence.SilenceID, alert.Id, err)
+		}
+		return errorCode(err), err
+	}
+	s.audit(req, "silence", "alert", alert.Id, alert.Environment, &before, alert)
+	if err := service.RecordAlertTransition(alert, before.State, service.GetAlertStatus(alert).StartsAt); err != nil {
+		logrus.Errorf("Error while recording state transition of alert %s: %v", alert.Id, err)
 	}
 
 	apiContext.Write(toAlertResource(apiContext, alert))
@@ -256,15 +318,32 @@ func (s *Server) unsilenceAlert(rw http.ResponseWriter, req *http.Request) (errC
 		return http.StatusBadRequest, fmt.Errorf("Current state is not active, can not perform slience action")
 	}
 
-	err = util.RemoveSilence(alert)
-	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("Error while adding silence to AlertManager: %v", err)
+	//alerts suppressed by a maintenance window or an inhibition have no silence of their own
+	if alert.Silence.SilenceID == "" {
+		return http.StatusBadRequest, fmt.Errorf("Alert is not silenced, can not perform unsilence action")
 	}
 
+	//the alert is updated first, so that a conflicting update leaves its silence in place
+	before := *alert
 	alert.State = model.AlertStateActive
+	alert.Silence = model.AlertSilenceSpec{}
 	err = service.UpdateAlert(alert)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return errorCode(err), err
+	}
+
+	err = util.DeleteSilence(before.Silence.SilenceID)
+	if err != nil {
+		alert.State = before.State
+		alert.Silence = before.Silence
+		if err := service.UpdateAlert(alert); err != nil {
+			logrus.Errorf("Error while restoring silence of alert %s: %v", alert.Id, err)
+		}
+		return http.StatusInternalServerError, fmt.Errorf("Error while deleting silence from AlertManager: %v", err)
+	}
+	s.audit(req, "unsilence", "alert", alert.Id, alert.Environment, &before, alert)
+	if err := service.RecordAlertTransition(alert, before.State, service.GetAlertStatus(alert).StartsAt); err != nil {
+		logrus.Errorf("Error while recording state transition of alert %s: %v", alert.Id, err)
 	}
 
 	apiContext.Write(toAlertResource(apiContext, alert))
@@ -272,26 +351,317 @@ func (s *Server) unsilenceAlert(rw http.ResponseWriter, req *http.Request) (errC
 
 }
 
+// checkAlertDependencies checks that the alerts the alert depends on exist in
+// its environment and that they do not depend on it in turn, as alerts
+// inhibiting each other would never notify.
+func checkAlertDependencies(alert *model.Alert) error {
+	for _, label := range alert.DependencyLabels {
+		if !prommodel.LabelName(label).IsValid() {
+			return fmt.Errorf("Invalid dependency label %s", label)
+		}
+	}
+
+	if len(alert.DependsOn) == 0 {
+		return nil
+	}
+
+	alertList, err := service.ListAlert(alert.Environment)
+	if err != nil {
+		return err
+	}
+
+	dependencies := map[string][]string{}
+	alerts := map[string]*model.Alert{}
+	for _, a := range alertList {
+		dependencies[a.Id] = a.DependsOn
+		alerts[a.Id] = a
+	}
+	for _, id := range alert.DependsOn {
+		if _, ok := dependencies[id]; !ok {
+			return fmt.Errorf("unable to find the alert %s to depend on in environment %s", id, alert.Environment)
+		}
+	}
+
+	//Alertmanager inhibits on equal values, so the series of the alert and
+	//those of its dependencies must all carry the dependency labels
+	for _, label := range alert.DependencyLabels {
+		if !sync.HasSeriesLabel(alert, label) {
+			return fmt.Errorf("The series of %s alerts have no dependency label %s", alert.TargetType, label)
+		}
+		for _, id := range alert.DependsOn {
+			if !sync.HasSeriesLabel(alerts[id], label) {
+				return fmt.Errorf("The series of the alert %s to depend on have no dependency label %s", id, label)
+			}
+		}
+	}
+	dependencies[alert.Id] = alert.DependsOn
+
+	//walk the dependencies looking for the alert itself
+	visited := map[string]bool{}
+	pending := append([]string{}, alert.DependsOn...)
+	for len(pending) > 0 {
+		id := pending[0]
+		pending = pending[1:]
+		if id == alert.Id {
+			return fmt.Errorf("the dependencies of alert %s form a cycle", alert.Id)
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		pending = append(pending, dependencies[id]...)
+	}
+
+	return nil
+}
+
+// getSilence returns the silence described by the silence action input,
+// created by the acting user.
+func getSilence(req *http.Request) (*model.AlertSilenceSpec, error) {
+	input := model.SilenceInput{}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 0 {
+		if err := json.Unmarshal(data, &input); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	silence := &model.AlertSilenceSpec{
+		Comment:   input.Comment,
+		CreatedBy: getActor(req),
+		StartsAt:  now,
+	}
+	if silence.Comment == "" {
+		silence.Comment = "silence"
+	}
+
+	switch {
+	case input.Duration != "" && !input.EndsAt.IsZero():
+		return nil, fmt.Errorf("only one of duration and endsAt can be set")
+	case input.Duration != "":
+		d, err := prommodel.ParseDuration(input.Duration)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("Invalid duration %s", input.Duration)
+		}
+		silence.EndsAt = now.Add(time.Duration(d))
+	case !input.EndsAt.IsZero():
+		if !input.EndsAt.After(now) {
+			return nil, fmt.Errorf("endsAt must be in the future")
+		}
+		silence.EndsAt = input.EndsAt
+	default:
+		silence.EndsAt = now.AddDate(100, 0, 0)
+	}
+
+	return silence, nil
+}
+
 func (s *Server) checkAlertParam(alert *model.Alert) error {
+	if err := checkAlertRule(alert); err != nil {
+		return err
+	}
+
+	//keep recipientId in sync for clients only aware of a single recipient
+	alert.RecipientIDs = alert.GetRecipientIDs()
+	if len(alert.RecipientIDs) == 0 {
+		return &fieldError{code: codeMissingRequired, field: "recipientIds", msg: "missing Recipient ID"}
+	}
+	alert.RecipientID = alert.RecipientIDs[0]
 
+	return nil
+}
+
+// checkAlertRule checks the fields of the alert its rule is generated from,
+// which is all a backtest needs.
+func checkAlertRule(alert *model.Alert) error {
 	if alert.Environment == "" {
-		return fmt.Errorf("missing environment")
+		return &fieldError{code: codeMissingRequired, field: "environment", msg: "missing environment"}
 	}
 
 	if alert.Description == "" {
-		return fmt.Errorf("missing description")
+		return &fieldError{code: codeMissingRequired, field: "description", msg: "missing description"}
+	}
+
+	if err := checkAlertLabels(alert); err != nil {
+		return err
+	}
+
+	if !contains(model.TargetTypes, alert.TargetType) {
+		return &fieldError{code: codeInvalidValue, field: "targetType", msg: "Invalid Target Type"}
+	}
+
+	if !alert.TargetSelector.IsEmpty() {
+		switch alert.TargetType {
+		case model.TargetTypeService:
+		case model.TargetTypeHost, model.TargetTypeHostResource:
+			if alert.TargetSelector.StackID != "" {
+				return &fieldError{code: codeInvalidValue, field: "targetSelector.stackId", msg: "stack selector only applies to service alerts"}
+			}
+		default:
+			return &fieldError{
+				code:  codeInvalidValue,
+				field: "targetSelector",
+				msg:   fmt.Sprintf("target selector is not supported for %s alerts", alert.TargetType),
+			}
+		}
+		if alert.TargetID != "" {
+			return &fieldError{code: codeInvalidValue, field: "targetId", msg: "target id and target selector can not be both set"}
+		}
+	} else if alert.TargetType != model.TargetTypeMetric && alert.TargetType != model.TargetTypeContainer && alert.TargetID == "" {
+		return &fieldError{code: codeMissingRequired, field: "targetId", msg: "missing Target Id"}
+	}
+
+	if err := checkAlertDurations(alert); err != nil {
+		return err
+	}
+
+	switch alert.TargetType {
+	case model.TargetTypeMetric:
+		return checkAlertExpr(alert)
+
+	case model.TargetTypeHostResource:
+		rule := alert.HostResourceRule
+		if !contains(model.HostResources, rule.Metric) {
+			return &fieldError{
+				code:  codeInvalidValue,
+				field: "hostResourceRule.metric",
+				msg:   fmt.Sprintf("Invalid host resource metric %s", rule.Metric),
+			}
+		}
+		return checkOperator("hostResourceRule.operator", rule.Operator)
+
+	case model.TargetTypeContainer:
+		rule := alert.ContainerRule
+		selectors := 0
+		if alert.TargetID != "" {
+			selectors++
+		}
+		if rule.ServiceID != "" {
+			selectors++
+		}
+		if len(rule.Labels) != 0 {
+			selectors++
+		}
+		if selectors != 1 {
+			return &fieldError{
+				code:  codeInvalidValue,
+				field: "containerRule",
+				msg:   "container alert needs exactly one of target id, service id or labels",
+			}
+		}
+		if !contains(model.ContainerMetrics, rule.Metric) {
+			return &fieldError{
+				code:  codeInvalidValue,
+				field: "containerRule.metric",
+				msg:   fmt.Sprintf("Invalid container metric %s", rule.Metric),
+			}
+		}
+		return checkOperator("containerRule.operator", rule.Operator)
 	}
 
-	if alert.RecipientID == "" {
-		return fmt.Errorf("missing Recipient ID")
+	return nil
+}
+
+// reservedLabels are the labels set by the manager on every alert
+var reservedLabels = []string{"alert_id", "alertname", "severity", "description", "target_type", "target_id", "environment"}
+
+func checkAlertLabels(alert *model.Alert) error {
+	for name, value := range alert.Labels {
+		field := "labels." + name
+		if !prommodel.LabelName(name).IsValid() || strings.HasPrefix(name, "__") {
+			return &fieldError{code: codeInvalidValue, field: field, msg: fmt.Sprintf("Invalid label name %s", name)}
+		}
+		if contains(reservedLabels, name) {
+			return &fieldError{code: codeInvalidValue, field: field, msg: fmt.Sprintf("label %s is reserved", name)}
+		}
+		if err := util.CheckRuleTemplate(name, value); err != nil {
+			return &fieldError{code: codeInvalidTemplate, field: field, msg: fmt.Sprintf("Invalid template in label %s: %v", name, err)}
+		}
+	}
+
+	//the fields of the annotations, summary and runbook_url are set from
+	//their own fields of the alert
+	annotations := map[string][2]string{}
+	for name, value := range alert.Annotations {
+		annotations[name] = [2]string{"annotations." + name, value}
+	}
+	annotations["summary"] = [2]string{"summary", alert.Summary}
+	annotations["runbook_url"] = [2]string{"runbookUrl", alert.RunbookURL}
+
+	for name, a := range annotations {
+		if !prommodel.LabelName(name).IsValid() {
+			return &fieldError{code: codeInvalidValue, field: a[0], msg: fmt.Sprintf("Invalid annotation name %s", name)}
+		}
+		if err := util.CheckRuleTemplate(name, a[1]); err != nil {
+			return &fieldError{code: codeInvalidTemplate, field: a[0], msg: fmt.Sprintf("Invalid template in annotation %s: %v", name, err)}
+		}
 	}
 
-	if !(alert.TargetType == "host" || alert.TargetType == "stack" || alert.TargetType == "service" || alert.TargetType == "metric") {
-		return fmt.Errorf("Invalid Target Type")
+	return nil
+}
+
+func checkOperator(field, operator string) error {
+	if !contains(model.Operators, operator) {
+		return &fieldError{code: codeInvalidValue, field: field, msg: fmt.Sprintf("Invalid operator %s", operator)}
+	}
+	return nil
+}
+
+// checkAlertDurations parses the durations used by the rule and the route of
+// the alert, where an empty duration is the default.
+func checkAlertDurations(alert *model.Alert) error {
+	durations := [][2]string{
+		{"advancedOptions.initialWait", alert.AdvancedOptions.InitialWait},
+		{"advancedOptions.repeatInterval", alert.AdvancedOptions.RepeatInterval},
+	}
+
+	switch alert.TargetType {
+	case model.TargetTypeMetric:
+		durations = append(durations, [2]string{"metricRule.holdDuration", alert.MetricRule.HoldDuration})
+	case model.TargetTypeService:
+		durations = append(durations, [2]string{"serviceRule.holdDuration", alert.ServiceRule.HoldDuration})
+	case model.TargetTypeStack:
+		durations = append(durations, [2]string{"stackRule.holdDuration", alert.StackRule.HoldDuration})
+	case model.TargetTypeHost:
+		durations = append(durations, [2]string{"hostRule.holdDuration", alert.HostRule.HoldDuration})
+	case model.TargetTypeHostResource:
+		durations = append(durations, [2]string{"hostResourceRule.holdDuration", alert.HostResourceRule.HoldDuration})
+	case model.TargetTypeContainer:
+		durations = append(durations,
+			[2]string{"containerRule.holdDuration", alert.ContainerRule.HoldDuration},
+			[2]string{"containerRule.window", alert.ContainerRule.Window})
+	}
+
+	for _, d := range durations {
+		if d[1] == "" {
+			continue
+		}
+		if _, err := prommodel.ParseDuration(d[1]); err != nil {
+			return &fieldError{
+				code:  codeInvalidDuration,
+				field: d[0],
+				msg:   fmt.Sprintf("Invalid duration %s of %s: %v", d[1], d[0], err),
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkAlertExpr checks that the expression of a metric alert parses as
+// PromQL.
+func checkAlertExpr(alert *model.Alert) error {
+	field := "metricRule.expr"
+	if strings.TrimSpace(alert.MetricRule.Expr) == "" {
+		return &fieldError{code: codeMissingRequired, field: field, msg: "missing expression"}
 	}
 
-	if alert.TargetType != "metric" && alert.TargetID == "" {
-		return fmt.Errorf("missing Target Id")
+	if err := util.CheckExpr(alert.MetricRule.Expr); err != nil {
+		return &fieldError{code: codeInvalidExpression, field: field, msg: err.Error()}
 	}
 
 	return nil
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+)
+
+// actorHeaders are the request headers identifying who made a change, in
+// order of preference.
+var actorHeaders = []string{"X-API-User-Id", "X-API-Account-Id", "X-Forwarded-User"}
+
+// secretFields are the fields of the config and recipients holding
+// credentials, or urls embedding them. They are not persisted in the audit
+// log, which only records whether they changed.
+var secretFields = [][]string{
+	{"emailConfig", "smtpAuthPassword"},
+	{"emailConfig", "smtpAuthSecret"},
+	{"slackConfig", "apiUrl"},
+	{"opsgenieConfig", "apiKey"},
+	{"victoropsConfig", "apiKey"},
+	{"hipchatConfig", "authToken"},
+	{"webhookRecipient", "basicAuthPassword"},
+	{"slackRecipient", "apiUrl"},
+	{"pagerdutyRecipient", "serviceKey"},
+	{"opsgenieRecipient", "apiKey"},
+	{"victoropsRecipient", "apiKey"},
+	{"hipchatRecipient", "authToken"},
+	{"pushoverRecipient", "userKey"},
+	{"pushoverRecipient", "token"},
+}
+
+// urlFields are the urls of the recipients which may embed credentials in
+// their userinfo or query. Only those parts are redacted, the rest of the url
+// is persisted.
+var urlFields = [][]string{
+	{"webhookRecipient", "url"},
+}
+
+// credentialParams are the substrings of the names of the query parameters
+// taken for credentials, e.g. token, api_key or X-Amz-Signature.
+var credentialParams = []string{"token", "key", "secret", "pass", "sig", "auth", "credential"}
+
+const (
+	secretChanged   = "changed"
+	secretUnchanged = "unchanged"
+)
+
+func (s *Server) listAuditLogs(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	vals := req.URL.Query()
+	environment := vals.Get("environment")
+	resourceID := vals.Get("resourceId")
+
+	var since, until time.Time
+	if v := vals.Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid since: %v", err)
+		}
+	}
+	if v := vals.Get("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("invalid until: %v", err)
+		}
+	}
+
+	logs, err := service.ListAuditLog(environment, resourceID, since, until)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(&client.GenericCollection{
+		Data: toAuditLogCollections(apiContext, logs),
+	})
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) getAuditLog(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	log, err := service.GetAuditLog(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	apiContext.Write(toAuditLogResource(apiContext, log))
+
+	return http.StatusOK, nil
+}
+
+// audit records a change made through the API. before is nil for creations
+// and after is nil for deletions. Failing to record it does not fail the
+// request.
+func (s *Server) audit(req *http.Request, action string, resourceType string, resourceID string, environment string, before interface{}, after interface{}) {
+	log := &model.AuditLog{
+		Environment:  environment,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Action:       action,
+		Actor:        getActor(req),
+	}
+	beforeData, afterData := auditData(before), auditData(after)
+	redactSecrets(beforeData, afterData)
+	log.Before = marshalAuditData(beforeData)
+	log.After = marshalAuditData(afterData)
+
+	if err := service.CreateAuditLog(log); err != nil {
+		logrus.Errorf("Error while recording audit log for %s %s %s: %v", action, resourceType, resourceID, err)
+	}
+}
+
+func getActor(req *http.Request) string {
+	for _, h := range actorHeaders {
+		if actor := req.Header.Get(h); actor != "" {
+			return actor
+		}
+	}
+
+	return req.RemoteAddr
+}
+
+// auditData returns the fields of a resource without its api links and
+// actions, or nil if obj is nil.
+func auditData(obj interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil
+	}
+	delete(data, "links")
+	delete(data, "actions")
+	delete(data, "type")
+
+	return data
+}
+
+func marshalAuditData(data map[string]interface{}) string {
+	if data == nil {
+		return ""
+	}
+
+	b, _ := json.Marshal(data)
+	return string(b)
+}
+
+// redactSecrets replaces the secret fields set in before and after with
+// whether the change modified them. A secret set on creation is changed.
+func redactSecrets(before map[string]interface{}, after map[string]interface{}) {
+	for _, path := range secretFields {
+		oldValue, hasOld := secretValue(before, path)
+		newValue, hasNew := secretValue(after, path)
+		if !hasOld && !hasNew {
+			continue
+		}
+
+		marker := secretUnchanged
+		if oldValue != newValue {
+			marker = secretChanged
+		}
+		if hasOld {
+			before[path[0]].(map[string]interface{})[path[1]] = marker
+		}
+		if hasNew {
+			after[path[0]].(map[string]interface{})[path[1]] = marker
+		}
+	}
+
+	for _, path := range urlFields {
+		oldValue, hasOld := secretValue(before, path)
+		newValue, hasNew := secretValue(after, path)
+		if hasOld {
+			before[path[0]].(map[string]interface{})[path[1]] = redactURL(oldValue, newValue)
+		}
+		if hasNew {
+			after[path[0]].(map[string]interface{})[path[1]] = redactURL(newValue, oldValue)
+		}
+	}
+}
+
+// redactURL replaces the password and the credential query parameters of
+// rawurl with whether they differ in other, the url it is compared with. A url
+// which does not parse is redacted as a whole.
+func redactURL(rawurl, other string) string {
+	marker := func(changed bool) string {
+		if changed {
+			return secretChanged
+		}
+		return secretUnchanged
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return marker(rawurl != other)
+	}
+	o, err := url.Parse(other)
+	if err != nil {
+		o = &url.URL{}
+	}
+
+	if password, ok := u.User.Password(); ok {
+		otherPassword, _ := o.User.Password()
+		u.User = url.UserPassword(u.User.Username(), marker(password != otherPassword))
+	}
+
+	//the parameters are rewritten in place, keeping their order and encoding
+	otherQuery := o.Query()
+	params := strings.Split(u.RawQuery, "&")
+	for i, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		name, err := url.QueryUnescape(kv[0])
+		if err != nil || len(kv) != 2 || !isCredentialParam(name) {
+			continue
+		}
+		value, _ := url.QueryUnescape(kv[1])
+		params[i] = kv[0] + "=" + marker(value != otherQuery.Get(name))
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	return u.String()
+}
+
+func isCredentialParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range credentialParams {
+		if strings.Contains(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretValue returns the value of the secret field at path in data, and
+// whether it is set.
+func secretValue(data map[string]interface{}, path []string) (string, bool) {
+	spec, ok := data[path[0]].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	value, _ := spec[path[1]].(string)
+
+	return value, value != ""
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/rancher/go-rancher/api"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/sync"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+// maxBacktestSteps is the most steps Prometheus evaluates in a range query.
+const maxBacktestSteps = 11000
+
+func (s *Server) backtestAlert(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	alert, err := service.GetAlert(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	input, err := getBacktestInput(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	backtest, errCode, err := runBacktest(alert, input)
+	if err != nil {
+		return errCode, err
+	}
+	backtest.AlertID = alert.Id
+
+	apiContext.Write(toAlertBacktestResource(apiContext, backtest))
+	return http.StatusOK, nil
+}
+
+// backtestAlerts backtests the alert of the input, before it is created.
+func (s *Server) backtestAlerts(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	input, err := getBacktestInput(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	alert := input.Alert
+	if alert == nil {
+		return http.StatusBadRequest, fmt.Errorf("missing alert")
+	}
+	if err = checkAlertRule(alert); err != nil {
+		return paramErrorCode(err), err
+	}
+
+	backtest, errCode, err := runBacktest(alert, input)
+	if err != nil {
+		return errCode, err
+	}
+
+	apiContext.Write(toAlertBacktestResource(apiContext, backtest))
+	return http.StatusOK, nil
+}
+
+func runBacktest(alert *model.Alert, input *model.BacktestInput) (*model.AlertBacktest, int, error) {
+	rule, err := sync.AlertRule(alert)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("Error while generating rule for alert: %v", err)
+	}
+
+	step, _ := prommodel.ParseDuration(input.Step)
+	backtest, err := service.BacktestAlert(rule.Expr, time.Duration(rule.For), input.Start, input.End, time.Duration(step))
+	if _, invalid := err.(*util.ExprError); invalid {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Error while querying Prometheus: %v", err)
+	}
+
+	return backtest, http.StatusOK, nil
+}
+
+// getBacktestInput returns the input of the backtest actions, with the range
+// and step defaulted.
+func getBacktestInput(req *http.Request) (*model.BacktestInput, error) {
+	input := &model.BacktestInput{}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 0 {
+		if err := json.Unmarshal(data, input); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.End.IsZero() {
+		input.End = time.Now()
+	}
+	if input.Start.IsZero() {
+		input.Start = input.End.Add(-24 * time.Hour)
+	}
+	if !input.Start.Before(input.End) {
+		return nil, fmt.Errorf("start must be before end")
+	}
+
+	if input.Step == "" {
+		input.Step = "1m"
+	}
+	step, err := prommodel.ParseDuration(input.Step)
+	if err != nil || step <= 0 {
+		return nil, fmt.Errorf("invalid step %s", input.Step)
+	}
+	if input.End.Sub(input.Start)/time.Duration(step) > maxBacktestSteps {
+		return nil, fmt.Errorf("too many steps of %s from start to end, the most is %d", input.Step, maxBacktestSteps)
+	}
+
+	return input, nil
+}
@@ -1,13 +1,20 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/rancher/go-rancher/api"
 	"github.com/rancher/go-rancher/client"
 	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
 )
 
+// defaultPageLimit is the page size of paginated collections when no limit is given
+const defaultPageLimit = 100
+
 type Server struct {
 	promChan  chan<- struct{}
 	alertChan chan<- struct{}
@@ -21,6 +28,120 @@ func NewServer(promChan, alertChan chan<- struct{}) *Server {
 	}
 }
 
+// fieldError is an invalid field of a request, reported with its code and
+// with the path of the field, e.g. metricRule.expr, as the detail of the error.
+type fieldError struct {
+	code  string
+	field string
+	msg   string
+}
+
+func (e *fieldError) Error() string {
+	return e.msg
+}
+
+// Codes of the field errors.
+const (
+	codeMissingRequired   = "MissingRequired"
+	codeInvalidExpression = "InvalidExpression"
+	codeInvalidDuration   = "InvalidDuration"
+	codeInvalidValue      = "InvalidValue"
+	codeInvalidTemplate   = "InvalidTemplate"
+)
+
+// paramErrorCode maps an error checking the parameters of a request to a http
+// status code, invalid fields are unprocessable entities
+func paramErrorCode(err error) int {
+	if _, ok := err.(*fieldError); ok {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+// errorCode maps an error returned by the service layer to a http status code
+func errorCode(err error) int {
+	if err == service.ErrConflict {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// paginate returns the page of data selected by the limit and marker query
+// parameters, where the marker is the offset of the first item of the page.
+func paginate(apiContext *api.ApiContext, req *http.Request, data []interface{}) (*client.GenericCollection, error) {
+	vals := req.URL.Query()
+
+	limit := int64(defaultPageLimit)
+	if v := vals.Get("limit"); v != "" {
+		l, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || l <= 0 {
+			return nil, fmt.Errorf("invalid limit %s", v)
+		}
+		limit = l
+	}
+
+	offset := int64(0)
+	if v := vals.Get("marker"); v != "" {
+		o, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || o < 0 {
+			return nil, fmt.Errorf("invalid marker %s", v)
+		}
+		offset = o
+	}
+
+	total := int64(len(data))
+	start, end := offset, offset+limit
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+
+	pageLink := func(marker int64) string {
+		q := url.Values{}
+		for k, v := range vals {
+			q[k] = v
+		}
+		q.Set("limit", strconv.FormatInt(limit, 10))
+		q.Set("marker", strconv.FormatInt(marker, 10))
+		return apiContext.UrlBuilder.Current() + "?" + q.Encode()
+	}
+
+	pagination := &client.Pagination{
+		Marker: strconv.FormatInt(offset, 10),
+		Limit:  &limit,
+		Total:  &total,
+	}
+	if end < total {
+		pagination.Next = pageLink(end)
+		pagination.Partial = true
+	}
+	if offset > 0 {
+		previous := offset - limit
+		if previous < 0 {
+			previous = 0
+		}
+		pagination.First = pageLink(0)
+		pagination.Previous = pageLink(previous)
+	}
+
+	return &client.GenericCollection{
+		Collection: client.Collection{Pagination: pagination},
+		Data:       data[start:end],
+	}, nil
+}
+
+// contains reports whether the value is one of the options
+func contains(options []string, value string) bool {
+	for _, o := range options {
+		if o == value {
+			return true
+		}
+	}
+	return false
+}
+
 func newSchema() *client.Schemas {
 	schemas := &client.Schemas{}
 
@@ -30,6 +151,18 @@ func newSchema() *client.Schemas {
 	recipientSchema(schemas.AddType("recipient", model.Recipient{}))
 	alertSchema(schemas.AddType("alert", model.Alert{}))
 	alertConfigSchema(schemas.AddType("config", model.AlertConfig{}))
+	auditLogSchema(schemas.AddType("auditLog", model.AuditLog{}))
+	maintenanceWindowSchema(schemas.AddType("maintenanceWindow", model.MaintenanceWindow{}))
+	alertEventSchema(schemas.AddType("alertEvent", model.AlertEvent{}))
+	schemas.AddType("alertStats", model.AlertStats{})
+	schemas.AddType("silenceInput", model.SilenceInput{})
+	backtestInputSchema(schemas.AddType("backtestInput", model.BacktestInput{}))
+	schemas.AddType("alertBacktest", model.AlertBacktest{})
+	alertInstanceSchema(schemas.AddType("alertInstance", model.AlertInstance{}))
+	notificationTemplateSchema(schemas.AddType("notificationTemplate", model.NotificationTemplate{}))
+	schemas.AddType("notificationPreview", model.NotificationPreview{})
+	schemas.AddType("renderedConfig", model.RenderedConfig{})
+	renderProposalSchema(schemas.AddType("renderProposal", model.RenderProposal{}))
 
 	return schemas
 }
@@ -64,7 +197,7 @@ func alertSchema(alert *client.Schema) {
 	targetType.Create = true
 	targetType.Update = false
 	targetType.Type = "enum"
-	targetType.Options = []string{"host", "service", "stack", "metric"}
+	targetType.Options = model.TargetTypes
 	alert.ResourceFields["targetType"] = targetType
 
 	targetId := alert.ResourceFields["targetId"]
@@ -78,14 +211,54 @@ func alertSchema(alert *client.Schema) {
 	recipientId.Type = "reference[recipient]"
 	alert.ResourceFields["recipientId"] = recipientId
 
+	recipientIds := alert.ResourceFields["recipientIds"]
+	recipientIds.Create = true
+	recipientIds.Update = true
+	recipientIds.Type = "array[reference[recipient]]"
+	alert.ResourceFields["recipientIds"] = recipientIds
+
 	environment := alert.ResourceFields["environment"]
 	environment.Create = true
 	environment.Required = true
 	environment.Update = false
 	alert.ResourceFields["environment"] = environment
 
+	resourceVersion := alert.ResourceFields["resourceVersion"]
+	resourceVersion.Create = false
+	resourceVersion.Update = true
+	alert.ResourceFields["resourceVersion"] = resourceVersion
+
+	flapping := alert.ResourceFields["flapping"]
+	flapping.Create = false
+	flapping.Update = false
+	alert.ResourceFields["flapping"] = flapping
+
+	silence := alert.ResourceFields["silence"]
+	silence.Create = false
+	silence.Update = false
+	alert.ResourceFields["silence"] = silence
+
+	maintenanceWindowID := alert.ResourceFields["maintenanceWindowId"]
+	maintenanceWindowID.Create = false
+	maintenanceWindowID.Update = false
+	maintenanceWindowID.Type = "reference[maintenanceWindow]"
+	alert.ResourceFields["maintenanceWindowId"] = maintenanceWindowID
+
+	dependsOn := alert.ResourceFields["dependsOn"]
+	dependsOn.Create = true
+	dependsOn.Update = true
+	dependsOn.Type = "array[reference[alert]]"
+	alert.ResourceFields["dependsOn"] = dependsOn
+
+	instances := alert.ResourceFields["instances"]
+	instances.Create = false
+	instances.Update = false
+	instances.Type = "array[alertInstance]"
+	alert.ResourceFields["instances"] = instances
+
 	alert.ResourceActions = map[string]client.Action{
 		"silence": {
+			Input:  "silenceInput",
 			Output: "alert",
 		},
 		"unsilence": {
@@ -97,6 +270,16 @@ func alertSchema(alert *client.Schema) {
 		"disable": {
 			Output: "alert",
 		},
+		"backtest": {
+			Input:  "backtestInput",
+			Output: "alertBacktest",
+		},
+	}
+	alert.CollectionActions = map[string]client.Action{
+		"backtest": {
+			Input:  "backtestInput",
+			Output: "alertBacktest",
+		},
 	}
 }
 
@@ -110,16 +293,41 @@ func recipientSchema(recipient *client.Schema) {
 	environment.Update = false
 	recipient.ResourceFields["environment"] = environment
 
+	resourceVersion := recipient.ResourceFields["resourceVersion"]
+	resourceVersion.Create = false
+	resourceVersion.Update = true
+	recipient.ResourceFields["resourceVersion"] = resourceVersion
+
 	recipientType := recipient.ResourceFields["recipientType"]
 	recipientType.Create = true
 	recipientType.Update = false
 	recipientType.Type = "enum"
-	recipientType.Options = []string{"email", "webhook"}
+	recipientType.Options = model.RecipientTypes
 	recipient.ResourceFields["recipientType"] = recipientType
+
+	templateID := recipient.ResourceFields["templateId"]
+	templateID.Type = "reference[notificationTemplate]"
+	recipient.ResourceFields["templateId"] = templateID
+
+	//unset keeps the default of the integration
+	sendResolved := recipient.ResourceFields["sendResolved"]
+	sendResolved.Type = "bool"
+	sendResolved.Nullable = true
+	recipient.ResourceFields["sendResolved"] = sendResolved
+
+	recipient.ResourceActions = map[string]client.Action{
+		"test": {
+			Output: "recipient",
+		},
+	}
 }
 
 func alertConfigSchema(config *client.Schema) {
 	config.CollectionMethods = []string{http.MethodGet, http.MethodPost}
+
+	defaultRecipientId := config.ResourceFields["defaultRecipientId"]
+	defaultRecipientId.Type = "reference[recipient]"
+	config.ResourceFields["defaultRecipientId"] = defaultRecipientId
 	config.ResourceActions = map[string]client.Action{
 		"update": client.Action{
 			Output: "config",
@@ -127,6 +335,117 @@ func alertConfigSchema(config *client.Schema) {
 	}
 }
 
+func auditLogSchema(auditLog *client.Schema) {
+	auditLog.CollectionMethods = []string{http.MethodGet}
+	auditLog.ResourceMethods = []string{http.MethodGet}
+}
+
+func alertEventSchema(alertEvent *client.Schema) {
+	alertEvent.CollectionMethods = []string{http.MethodGet}
+	alertEvent.ResourceMethods = []string{http.MethodGet}
+
+	event := alertEvent.ResourceFields["event"]
+	event.Type = "enum"
+	event.Options = []string{model.AlertEventFired, model.AlertEventSuppressed, model.AlertEventReactivated, model.AlertEventResolved}
+	alertEvent.ResourceFields["event"] = event
+}
+
+func maintenanceWindowSchema(window *client.Schema) {
+	window.CollectionMethods = []string{http.MethodGet, http.MethodPost}
+	window.ResourceMethods = []string{http.MethodGet, http.MethodDelete, http.MethodPut}
+
+	environment := window.ResourceFields["environment"]
+	environment.Create = true
+	environment.Required = true
+	environment.Update = false
+	window.ResourceFields["environment"] = environment
+
+	targetType := window.ResourceFields["targetType"]
+	targetType.Create = true
+	targetType.Update = true
+	targetType.Type = "enum"
+	targetType.Options = []string{model.TargetTypeHost, model.TargetTypeStack, model.TargetTypeService}
+	window.ResourceFields["targetType"] = targetType
+
+	duration := window.ResourceFields["duration"]
+	duration.Create = true
+	duration.Update = true
+	duration.Required = true
+	window.ResourceFields["duration"] = duration
+
+	resourceVersion := window.ResourceFields["resourceVersion"]
+	resourceVersion.Create = false
+	resourceVersion.Update = true
+	window.ResourceFields["resourceVersion"] = resourceVersion
+
+	for _, name := range []string{"active", "activeFrom", "activeTo", "silenceIds"} {
+		field := window.ResourceFields[name]
+		field.Create = false
+		field.Update = false
+		window.ResourceFields[name] = field
+	}
+}
+
+func alertInstanceSchema(alertInstance *client.Schema) {
+	alertInstance.CollectionMethods = []string{http.MethodGet}
+	alertInstance.ResourceMethods = []string{http.MethodGet}
+
+	alertInstance.ResourceActions = map[string]client.Action{
+		"silence": {
+			Input:  "silenceInput",
+			Output: "alertInstance",
+		},
+	}
+}
+
+func notificationTemplateSchema(template *client.Schema) {
+	template.CollectionMethods = []string{http.MethodGet, http.MethodPost}
+	template.ResourceMethods = []string{http.MethodGet, http.MethodDelete, http.MethodPut}
+
+	environment := template.ResourceFields["environment"]
+	environment.Create = true
+	environment.Required = true
+	environment.Update = false
+	template.ResourceFields["environment"] = environment
+
+	name := template.ResourceFields["name"]
+	name.Create = true
+	name.Required = true
+	name.Update = true
+	template.ResourceFields["name"] = name
+
+	resourceVersion := template.ResourceFields["resourceVersion"]
+	resourceVersion.Create = false
+	resourceVersion.Update = true
+	template.ResourceFields["resourceVersion"] = resourceVersion
+
+	template.ResourceActions = map[string]client.Action{
+		"preview": {
+			Output: "notificationPreview",
+		},
+	}
+}
+
+func backtestInputSchema(input *client.Schema) {
+	//pointer fields are left out of the schema
+	input.ResourceFields["alert"] = client.Field{
+		Type:     "alert",
+		Nullable: true,
+	}
+}
+
+func renderProposalSchema(proposal *client.Schema) {
+	//pointer fields are left out of the schema
+	proposal.ResourceFields["alert"] = client.Field{
+		Type:     "alert",
+		Nullable: true,
+	}
+	proposal.ResourceFields["recipient"] = client.Field{
+		Type:     "recipient",
+		Nullable: true,
+	}
+}
+
 func toAlertConfigResource(apiContext *api.ApiContext, config *model.AlertConfig) *model.AlertConfig {
 	config.Resource = client.Resource{
 		Type:    "config",
@@ -138,6 +457,65 @@ func toAlertConfigResource(apiContext *api.ApiContext, config *model.AlertConfig
 	return config
 }
 
+func toMaintenanceWindowCollections(apiContext *api.ApiContext, windows []*model.MaintenanceWindow) []interface{} {
+	r := []interface{}{}
+	for _, w := range windows {
+		r = append(r, toMaintenanceWindowResource(apiContext, w))
+	}
+	return r
+}
+
+func toMaintenanceWindowResource(apiContext *api.ApiContext, window *model.MaintenanceWindow) *model.MaintenanceWindow {
+	window.Resource = client.Resource{
+		Id:      window.Id,
+		Type:    "maintenanceWindow",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	window.Resource.Links["update"] = apiContext.UrlBuilder.ReferenceByIdLink("maintenanceWindow", window.Id)
+	window.Resource.Links["remove"] = apiContext.UrlBuilder.ReferenceByIdLink("maintenanceWindow", window.Id)
+	window.Resource.Links["self"] = apiContext.UrlBuilder.ReferenceByIdLink("maintenanceWindow", window.Id)
+
+	return window
+}
+
+func toNotificationTemplateCollections(apiContext *api.ApiContext, templates []*model.NotificationTemplate) []interface{} {
+	r := []interface{}{}
+	for _, t := range templates {
+		r = append(r, toNotificationTemplateResource(apiContext, t))
+	}
+	return r
+}
+
+func toNotificationTemplateResource(apiContext *api.ApiContext, template *model.NotificationTemplate) *model.NotificationTemplate {
+	template.Resource = client.Resource{
+		Id:      template.Id,
+		Type:    "notificationTemplate",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	template.Resource.Links["update"] = apiContext.UrlBuilder.ReferenceByIdLink("notificationTemplate", template.Id)
+	template.Resource.Links["remove"] = apiContext.UrlBuilder.ReferenceByIdLink("notificationTemplate", template.Id)
+	template.Resource.Links["self"] = apiContext.UrlBuilder.ReferenceByIdLink("notificationTemplate", template.Id)
+	template.Actions["preview"] = apiContext.UrlBuilder.ReferenceLink(template.Resource) + "?action=preview"
+
+	return template
+}
+
+func toNotificationPreviewResource(apiContext *api.ApiContext, template *model.NotificationTemplate, preview *model.NotificationPreview) *model.NotificationPreview {
+	preview.Resource = client.Resource{
+		Type:    "notificationPreview",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	preview.Resource.Links["notificationTemplate"] = apiContext.UrlBuilder.ReferenceByIdLink("notificationTemplate", template.Id)
+
+	return preview
+}
+
 func toRecipientCollections(apiContext *api.ApiContext, recipients []*model.Recipient) []interface{} {
 	var r []interface{}
 	for _, p := range recipients {
@@ -157,6 +535,10 @@ func toRecipientResource(apiContext *api.ApiContext, recipient *model.Recipient)
 	recipient.Resource.Links["update"] = apiContext.UrlBuilder.ReferenceByIdLink("recipient", recipient.Id)
 	recipient.Resource.Links["remove"] = apiContext.UrlBuilder.ReferenceByIdLink("recipient", recipient.Id)
 	recipient.Resource.Links["self"] = apiContext.UrlBuilder.ReferenceByIdLink("recipient", recipient.Id)
+	recipient.Actions["test"] = apiContext.UrlBuilder.ReferenceLink(recipient.Resource) + "?action=test"
+	if recipient.TemplateID != "" {
+		recipient.Resource.Links["template"] = apiContext.UrlBuilder.ReferenceByIdLink("notificationTemplate", recipient.TemplateID)
+	}
 
 	return recipient
 }
@@ -181,6 +563,19 @@ func toAlertResource(apiContext *api.ApiContext, alert *model.Alert) *model.Aler
 	alert.Resource.Links["remove"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", alert.Id)
 	alert.Resource.Links["self"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", alert.Id)
 	alert.Resource.Links["recipient"] = apiContext.UrlBuilder.ReferenceByIdLink("recipient", alert.RecipientID)
+	alert.Resource.Links["recipients"] = apiContext.UrlBuilder.Collection("recipient") + "?alertId=" + url.QueryEscape(alert.Id)
+	alert.Resource.Links["history"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", alert.Id) + "/history"
+	alert.Resource.Links["stats"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", alert.Id) + "/stats"
+	alert.Resource.Links["instances"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", alert.Id) + "/instances"
+	status := service.GetAlertStatus(alert)
+	alert.SelectorEmpty = status.SelectorEmpty
+	alert.StartsAt = status.StartsAt
+	alert.EndsAt = status.EndsAt
+	alert.Instances = status.Instances
+	alert.MaintenanceWindowID = status.MaintenanceWindowID
+	if alert.MaintenanceWindowID != "" {
+		alert.Resource.Links["maintenanceWindow"] = apiContext.UrlBuilder.ReferenceByIdLink("maintenanceWindow", alert.MaintenanceWindowID)
+	}
 	alert.Actions["enable"] = apiContext.UrlBuilder.ReferenceLink(alert.Resource) + "?action=enable"
 	alert.Actions["disable"] = apiContext.UrlBuilder.ReferenceLink(alert.Resource) + "?action=disable"
 	alert.Actions["silence"] = apiContext.UrlBuilder.ReferenceLink(alert.Resource) + "?action=silence"
@@ -188,3 +583,113 @@ func toAlertResource(apiContext *api.ApiContext, alert *model.Alert) *model.Aler
 
 	return alert
 }
+
+func toAuditLogCollections(apiContext *api.ApiContext, logs []*model.AuditLog) []interface{} {
+	var r []interface{}
+	for _, l := range logs {
+		r = append(r, toAuditLogResource(apiContext, l))
+	}
+	return r
+}
+
+func toAuditLogResource(apiContext *api.ApiContext, log *model.AuditLog) *model.AuditLog {
+	log.Resource = client.Resource{
+		Id:      log.Id,
+		Type:    "auditLog",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	log.Resource.Links["self"] = apiContext.UrlBuilder.ReferenceByIdLink("auditLog", log.Id)
+
+	return log
+}
+
+func toAlertEventCollections(apiContext *api.ApiContext, events []*model.AlertEvent) []interface{} {
+	r := []interface{}{}
+	for _, e := range events {
+		r = append(r, toAlertEventResource(apiContext, e))
+	}
+	return r
+}
+
+func toAlertEventResource(apiContext *api.ApiContext, event *model.AlertEvent) *model.AlertEvent {
+	event.Resource = client.Resource{
+		Id:      event.Id,
+		Type:    "alertEvent",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	event.Resource.Links["alert"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", event.AlertID)
+
+	return event
+}
+
+func toAlertInstanceCollections(apiContext *api.ApiContext, instances []*model.AlertInstance) []interface{} {
+	r := []interface{}{}
+	for _, i := range instances {
+		r = append(r, toAlertInstanceResource(apiContext, i))
+	}
+	return r
+}
+
+func toAlertInstanceResource(apiContext *api.ApiContext, instance *model.AlertInstance) *model.AlertInstance {
+	instance.Resource = client.Resource{
+		Id:      instance.Fingerprint,
+		Type:    "alertInstance",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	self := apiContext.UrlBuilder.ReferenceByIdLink("alert", instance.AlertID) + "/instances/" + instance.Fingerprint
+	instance.Resource.Links["self"] = self
+	instance.Resource.Links["alert"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", instance.AlertID)
+	if !instance.Silenced {
+		instance.Resource.Actions["silence"] = self + "?action=silence"
+	}
+
+	return instance
+}
+
+func toAlertBacktestResource(apiContext *api.ApiContext, backtest *model.AlertBacktest) *model.AlertBacktest {
+	backtest.Resource = client.Resource{
+		Type:    "alertBacktest",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	if backtest.AlertID != "" {
+		backtest.Resource.Links["alert"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", backtest.AlertID)
+	}
+
+	return backtest
+}
+
+func toRenderedConfigResource(apiContext *api.ApiContext, config *model.RenderedConfig) *model.RenderedConfig {
+	config.Resource = client.Resource{
+		Id:      config.Id,
+		Type:    "renderedConfig",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	config.Resource.Links["self"] = apiContext.UrlBuilder.Current()
+
+	return config
+}
+
+func toAlertStatsResource(apiContext *api.ApiContext, stats *model.AlertStats) *model.AlertStats {
+	stats.Resource = client.Resource{
+		Type:    "alertStats",
+		Actions: map[string]string{},
+		Links:   map[string]string{},
+	}
+
+	stats.Resource.Links["self"] = apiContext.UrlBuilder.Current()
+	if stats.AlertID != "" {
+		stats.Resource.Links["alert"] = apiContext.UrlBuilder.ReferenceByIdLink("alert", stats.AlertID)
+	}
+
+	return stats
+}
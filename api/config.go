@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
@@ -36,10 +37,22 @@ func (s *Server) updateAlertConfig(rw http.ResponseWriter, req *http.Request) (e
 		return http.StatusInternalServerError, err
 	}
 
+	if config.DefaultRecipientID != "" {
+		if _, err := service.GetRecipient(config.DefaultRecipientID); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("unable to find the default recipient: %v", err)
+		}
+	}
+
+	var before interface{}
+	if oriConfig, err := service.GetAlertConfig(); err == nil {
+		before = oriConfig
+	}
+
 	config, err = service.CreateOrUpdateAlertConfig(config)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	s.audit(req, "update", "config", model.AlertConfigKind, "", before, config)
 
 	go func() {
 		s.alertChan <- struct{}{}
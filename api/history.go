@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/rancher/go-rancher/api"
+	"github.com/zionwu/monitoring-manager/service"
+)
+
+func (s *Server) getAlertHistory(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	alert, err := service.GetAlert(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	events, err := service.ListAlertEvent(alert.Environment, alert.Id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	collection, err := paginate(apiContext, req, toAlertEventCollections(apiContext, events))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	apiContext.Write(collection)
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) listAlertHistory(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	events, err := service.ListAlertEvent(environment, "")
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	collection, err := paginate(apiContext, req, toAlertEventCollections(apiContext, events))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	apiContext.Write(collection)
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) getAlertEvent(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	event, err := service.GetAlertEvent(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	apiContext.Write(toAlertEventResource(apiContext, event))
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) getAlertStats(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	window, err := getStatsWindow(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	alert, err := service.GetAlert(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	stats, err := service.GetAlertStats(alert, window)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toAlertStatsResource(apiContext, stats))
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) getEnvironmentAlertStats(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	window, err := getStatsWindow(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	stats, err := service.GetEnvironmentAlertStats(environment, window)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toAlertStatsResource(apiContext, stats))
+
+	return http.StatusOK, nil
+}
+
+// getStatsWindow returns the window query parameter, 24 hours by default.
+func getStatsWindow(req *http.Request) (time.Duration, error) {
+	v := req.URL.Query().Get("window")
+	if v == "" {
+		return 24 * time.Hour, nil
+	}
+
+	window, err := prommodel.ParseDuration(v)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %s", v)
+	}
+
+	return time.Duration(window), nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+func (s *Server) listAlertInstances(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	alert, err := service.GetAlert(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	instances := service.GetAlertStatus(alert).Instances
+	collection, err := paginate(apiContext, req, toAlertInstanceCollections(apiContext, instances))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	apiContext.Write(collection)
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) getAlertInstance(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	_, instance, err := getInstance(req)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	apiContext.Write(toAlertInstanceResource(apiContext, instance))
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) silenceAlertInstance(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	alert, instance, err := getInstance(req)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	if instance.Silenced {
+		return http.StatusBadRequest, fmt.Errorf("Current instance is already silenced, can not perform slience action")
+	}
+
+	silence, err := getSilence(req)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = util.AddInstanceSilence(instance, silence)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Error while adding silence to AlertManager: %v", err)
+	}
+
+	//the alert state is brought up to date by the next state sync
+	before := *instance
+	instance.Silenced = true
+	instance.SilencedBy = append(instance.SilencedBy, silence.SilenceID)
+	s.audit(req, "silenceInstance", "alert", alert.Id, alert.Environment, &before, instance)
+
+	apiContext.Write(toAlertInstanceResource(apiContext, instance))
+	return http.StatusOK, nil
+}
+
+// getInstance returns the alert and its firing instance selected by the
+// request path.
+func getInstance(req *http.Request) (*model.Alert, *model.AlertInstance, error) {
+	vars := mux.Vars(req)
+
+	alert, err := service.GetAlert(vars["id"])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, instance := range service.GetAlertStatus(alert).Instances {
+		if instance.Fingerprint == vars["fingerprint"] {
+			return alert, instance, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("can not find the firing instance %s of alert %s", vars["fingerprint"], alert.Id)
+}
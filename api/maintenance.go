@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+func (s *Server) listMaintenanceWindows(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	windows, err := service.ListMaintenanceWindow(environment)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(&client.GenericCollection{
+		Data: toMaintenanceWindowCollections(apiContext, windows),
+	})
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) createMaintenanceWindow(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	data, err := ioutil.ReadAll(req.Body)
+	window := &model.MaintenanceWindow{}
+	logrus.Debugf("start create maintenance window, get data:%v", string(data))
+	if err := json.Unmarshal(data, window); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err = checkMaintenanceWindowParam(window); err != nil {
+		return http.StatusBadRequest, err
+	}
+	resetMaintenanceWindow(window)
+
+	err = service.CreateMaintenanceWindow(window)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	s.audit(req, "create", "maintenanceWindow", window.Id, window.Environment, nil, window)
+
+	apiContext.Write(toMaintenanceWindowResource(apiContext, window))
+	return http.StatusOK, nil
+}
+
+func (s *Server) getMaintenanceWindow(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	window, err := service.GetMaintenanceWindow(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	apiContext.Write(toMaintenanceWindowResource(apiContext, window))
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) deleteMaintenanceWindow(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	window, err := service.GetMaintenanceWindow(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	err = service.DeleteMaintenanceWindow(id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	expireMaintenanceSilences(window)
+	s.audit(req, "delete", "maintenanceWindow", window.Id, window.Environment, window, nil)
+
+	apiContext.Write(toMaintenanceWindowResource(apiContext, window))
+	return http.StatusOK, nil
+}
+
+func (s *Server) updateMaintenanceWindow(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	window := &model.MaintenanceWindow{}
+	data, err := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(data, window); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	oriWindow, err := service.GetMaintenanceWindow(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	window.Environment = oriWindow.Environment
+	if err = checkMaintenanceWindowParam(window); err != nil {
+		return http.StatusBadRequest, err
+	}
+	window.Id = id
+
+	//the schedule may have changed, let the synchronizer start over
+	resetMaintenanceWindow(window)
+
+	//clients not aware of resource versions update the latest one
+	if window.ResourceVersion == 0 {
+		window.ResourceVersion = oriWindow.ResourceVersion
+	}
+	if err = service.UpdateMaintenanceWindow(window); err != nil {
+		return errorCode(err), err
+	}
+	expireMaintenanceSilences(oriWindow)
+	s.audit(req, "update", "maintenanceWindow", window.Id, window.Environment, oriWindow, window)
+
+	apiContext.Write(toMaintenanceWindowResource(apiContext, window))
+	return http.StatusOK, nil
+}
+
+func resetMaintenanceWindow(window *model.MaintenanceWindow) {
+	window.Active = false
+	window.ActiveFrom = time.Time{}
+	window.ActiveTo = time.Time{}
+	window.SilenceIDs = nil
+}
+
+// expireMaintenanceSilences expires the silences of a window that is still
+// in progress. Failing to do so does not fail the request, the silences end
+// with the window anyway.
+func expireMaintenanceSilences(window *model.MaintenanceWindow) {
+	if !window.Active || !window.ActiveTo.After(time.Now()) {
+		return
+	}
+
+	for _, id := range window.SilenceIDs {
+		if err := util.DeleteSilence(id); err != nil {
+			logrus.Errorf("Error while expiring silence %s of maintenance window %s: %v", id, window.Id, err)
+		}
+	}
+}
+
+func checkMaintenanceWindowParam(window *model.MaintenanceWindow) error {
+
+	if window.Environment == "" {
+		return fmt.Errorf("missing environment")
+	}
+
+	switch window.TargetType {
+	case "":
+		if len(window.TargetIDs) != 0 {
+			return fmt.Errorf("missing Target Type")
+		}
+	case model.TargetTypeHost, model.TargetTypeStack, model.TargetTypeService:
+		if len(window.TargetIDs) == 0 {
+			return fmt.Errorf("missing Target Ids")
+		}
+	default:
+		return fmt.Errorf("Invalid Target Type")
+	}
+
+	d, err := prommodel.ParseDuration(window.Duration)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("Invalid duration %s", window.Duration)
+	}
+
+	if window.Schedule == "" && window.StartsAt.IsZero() {
+		return fmt.Errorf("missing schedule or startsAt")
+	}
+	if window.Schedule != "" && !window.StartsAt.IsZero() {
+		return fmt.Errorf("only one of schedule and startsAt can be set")
+	}
+
+	if window.Schedule != "" {
+		if _, err := util.ParseCron(window.Schedule); err != nil {
+			return err
+		}
+	}
+
+	if _, err := time.LoadLocation(window.Timezone); err != nil {
+		return fmt.Errorf("Invalid timezone %s: %v", window.Timezone, err)
+	}
+
+	return nil
+}
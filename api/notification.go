@@ -0,0 +1,177 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+func (s *Server) listNotificationTemplates(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	templates, err := service.ListNotificationTemplate(environment)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(&client.GenericCollection{
+		Data: toNotificationTemplateCollections(apiContext, templates),
+	})
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) createNotificationTemplate(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	data, err := ioutil.ReadAll(req.Body)
+	template := &model.NotificationTemplate{}
+	logrus.Debugf("start create notification template, get data:%v", string(data))
+	if err := json.Unmarshal(data, template); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if err = checkNotificationTemplateParam(template); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = service.CreateNotificationTemplate(template)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	s.audit(req, "create", "notificationTemplate", template.Id, template.Environment, nil, template)
+
+	apiContext.Write(toNotificationTemplateResource(apiContext, template))
+	return http.StatusOK, nil
+}
+
+func (s *Server) getNotificationTemplate(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	template, err := service.GetNotificationTemplate(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	apiContext.Write(toNotificationTemplateResource(apiContext, template))
+
+	return http.StatusOK, nil
+}
+
+func (s *Server) deleteNotificationTemplate(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	template, err := service.GetNotificationTemplate(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	recipients, err := service.ListRecipient(template.Environment)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, recipient := range recipients {
+		if recipient.TemplateID == template.Id {
+			return http.StatusBadRequest, fmt.Errorf("The notification template %s is still used by recipient %s", id, recipient.Id)
+		}
+	}
+
+	err = service.DeleteNotificationTemplate(id)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	s.audit(req, "delete", "notificationTemplate", template.Id, template.Environment, template, nil)
+
+	go func() {
+		s.alertChan <- struct{}{}
+	}()
+
+	apiContext.Write(toNotificationTemplateResource(apiContext, template))
+	return http.StatusOK, nil
+}
+
+func (s *Server) updateNotificationTemplate(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	template := &model.NotificationTemplate{}
+	data, err := ioutil.ReadAll(req.Body)
+	if err := json.Unmarshal(data, template); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	oriTemplate, err := service.GetNotificationTemplate(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	//recipients only use templates of their environment
+	template.Environment = oriTemplate.Environment
+	if err = checkNotificationTemplateParam(template); err != nil {
+		return http.StatusBadRequest, err
+	}
+	template.Id = id
+
+	//clients not aware of resource versions update the latest one
+	if template.ResourceVersion == 0 {
+		template.ResourceVersion = oriTemplate.ResourceVersion
+	}
+	if err = service.UpdateNotificationTemplate(template); err != nil {
+		return errorCode(err), err
+	}
+	s.audit(req, "update", "notificationTemplate", template.Id, template.Environment, oriTemplate, template)
+
+	go func() {
+		s.alertChan <- struct{}{}
+	}()
+
+	apiContext.Write(toNotificationTemplateResource(apiContext, template))
+	return http.StatusOK, nil
+}
+
+func (s *Server) previewNotificationTemplate(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	template, err := service.GetNotificationTemplate(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+
+	preview, err := util.RenderNotificationTemplate(template)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	apiContext.Write(toNotificationPreviewResource(apiContext, template, preview))
+	return http.StatusOK, nil
+}
+
+func checkNotificationTemplateParam(template *model.NotificationTemplate) error {
+
+	if template.Environment == "" {
+		return fmt.Errorf("missing environment")
+	}
+
+	if template.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+
+	if err := util.CheckNotificationTemplate(template); err != nil {
+		return fmt.Errorf("Invalid notification template: %v", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
@@ -12,6 +14,7 @@ import (
 	"github.com/rancher/go-rancher/client"
 	"github.com/zionwu/monitoring-manager/model"
 	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/sync"
 )
 
 func (s *Server) listRecipient(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
@@ -28,6 +31,22 @@ func (s *Server) listRecipient(rw http.ResponseWriter, req *http.Request) (errCo
 		return http.StatusInternalServerError, err
 	}
 
+	//the recipients of an alert, linked from it
+	if alertID := vals.Get("alertId"); alertID != "" {
+		alert, err := service.GetAlert(alertID)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		ids := alert.GetRecipientIDs()
+		filtered := []*model.Recipient{}
+		for _, recipient := range recipients {
+			if contains(ids, recipient.Id) {
+				filtered = append(filtered, recipient)
+			}
+		}
+		recipients = filtered
+	}
+
 	apiContext.Write(&client.GenericCollection{
 		Data: toRecipientCollections(apiContext, recipients),
 	})
@@ -54,6 +73,7 @@ func (s *Server) createRecipient(rw http.ResponseWriter, req *http.Request) (err
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	s.audit(req, "create", "recipient", recipient.Id, recipient.Environment, nil, recipient)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -70,7 +90,7 @@ func (s *Server) getRecipient(rw http.ResponseWriter, req *http.Request) (errCod
 
 	recipient, err := service.GetRecipient(id)
 	if err != nil {
-		logrus.Errorf("Error while getting recipient", err)
+		logrus.Errorf("Error while getting recipient: %v", err)
 		return http.StatusNotFound, err
 	}
 
@@ -88,14 +108,20 @@ func (s *Server) deleteRecipient(rw http.ResponseWriter, req *http.Request) (err
 		return http.StatusNotFound, err
 	}
 
-	//check if the recipient is used by any alert
+	//check if the recipient is the default one or used by any alert
+	if notifier, err := service.GetAlertConfig(); err == nil && notifier.DefaultRecipientID == recipient.Id {
+		return http.StatusBadRequest, fmt.Errorf("The recipient %s is still used as the default recipient", id)
+	}
+
 	alertList, err := service.ListAlert("")
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 	for _, alert := range alertList {
-		if alert.RecipientID == recipient.Id {
-			return http.StatusBadRequest, fmt.Errorf("The recipient %s is still used for alert", id)
+		for _, recipientID := range alert.GetRecipientIDs() {
+			if recipientID == recipient.Id {
+				return http.StatusBadRequest, fmt.Errorf("The recipient %s is still used for alert", id)
+			}
 		}
 	}
 
@@ -103,6 +129,7 @@ func (s *Server) deleteRecipient(rw http.ResponseWriter, req *http.Request) (err
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	s.audit(req, "delete", "recipient", recipient.Id, recipient.Environment, recipient, nil)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -123,7 +150,7 @@ func (s *Server) updateRecipient(rw http.ResponseWriter, req *http.Request) (err
 		return http.StatusInternalServerError, err
 	}
 
-	_, err = service.GetRecipient(id)
+	oriRecipient, err := service.GetRecipient(id)
 	if err != nil {
 		return http.StatusNotFound, err
 	}
@@ -133,7 +160,14 @@ func (s *Server) updateRecipient(rw http.ResponseWriter, req *http.Request) (err
 	}
 	recipient.Id = id
 
-	service.UpdateRecipient(recipient)
+	//clients not aware of resource versions update the latest one
+	if recipient.ResourceVersion == 0 {
+		recipient.ResourceVersion = oriRecipient.ResourceVersion
+	}
+	if err = service.UpdateRecipient(recipient); err != nil {
+		return errorCode(err), err
+	}
+	s.audit(req, "update", "recipient", recipient.Id, recipient.Environment, oriRecipient, recipient)
 
 	go func() {
 		s.alertChan <- struct{}{}
@@ -143,23 +177,41 @@ func (s *Server) updateRecipient(rw http.ResponseWriter, req *http.Request) (err
 	return http.StatusOK, nil
 }
 
-func (s *Server) checkRecipientParam(recipient *model.Recipient) error {
+func (s *Server) testRecipient(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	id := mux.Vars(req)["id"]
+
+	recipient, err := service.GetRecipient(id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
 
-	recipientType := recipient.RecipientType
-	if !(recipientType == "email" || recipientType == "webhook") {
-		return fmt.Errorf("recipientTpye should be email/webhook")
+	if err = sync.SendTestAlert(recipient); err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Error while sending test alert: %v", err)
 	}
 
+	apiContext.Write(toRecipientResource(apiContext, recipient))
+	return http.StatusOK, nil
+}
+
+func (s *Server) checkRecipientParam(recipient *model.Recipient) error {
+
 	if recipient.Environment == "" {
 		return fmt.Errorf("missing environment")
 	}
 
-	switch recipientType {
-	case "email":
+	//settings missing on the recipient fall back to the global config
+	notifier, err := service.GetAlertConfig()
+	if err != nil {
+		notifier = &model.AlertConfig{}
+	}
+
+	switch recipient.RecipientType {
+	case model.RecipientTypeEmail:
 		if recipient.EmailRecipient.Address == "" {
 			return fmt.Errorf("email address can't be empty")
 		}
-	case "webhook":
+	case model.RecipientTypeWebhook:
 		if recipient.WebhookRecipient.URL == "" {
 			return fmt.Errorf("webhook url can't be empty")
 		}
@@ -167,6 +219,73 @@ func (s *Server) checkRecipientParam(recipient *model.Recipient) error {
 		if recipient.WebhookRecipient.Name == "" {
 			return fmt.Errorf("webhook name can't be empty")
 		}
+
+		webhook := recipient.WebhookRecipient
+		if webhook.BasicAuthPassword != "" && webhook.BasicAuthUsername == "" {
+			return fmt.Errorf("webhook basic auth username can't be empty with a password")
+		}
+
+		if u, err := url.Parse(webhook.URL); err != nil {
+			return fmt.Errorf("webhook url is invalid: %v", err)
+		} else if webhook.BasicAuthUsername != "" && u.User != nil {
+			return fmt.Errorf("webhook basic auth can't be set with credentials in the url")
+		}
+	case model.RecipientTypeSlack:
+		if recipient.SlackRecipient.Channel == "" {
+			return fmt.Errorf("slack channel can't be empty")
+		}
+
+		if u := recipient.SlackRecipient.APIURL; u != "" {
+			if _, err := url.ParseRequestURI(u); err != nil {
+				return fmt.Errorf("invalid slack api url: %v", err)
+			}
+		} else if notifier.SlackConfig.APIURL == "" {
+			return fmt.Errorf("slack api url can't be empty without a global slack api url")
+		}
+	case model.RecipientTypePagerDuty:
+		if recipient.PagerDutyRecipient.ServiceKey == "" {
+			return fmt.Errorf("pagerduty service key can't be empty")
+		}
+	case model.RecipientTypeOpsGenie:
+		if recipient.OpsGenieRecipient.APIKey == "" && notifier.OpsGenieConfig.APIKey == "" {
+			return fmt.Errorf("opsgenie api key can't be empty without a global opsgenie api key")
+		}
+	case model.RecipientTypeVictorOps:
+		if recipient.VictorOpsRecipient.RoutingKey == "" {
+			return fmt.Errorf("victorops routing key can't be empty")
+		}
+
+		if recipient.VictorOpsRecipient.APIKey == "" && notifier.VictorOpsConfig.APIKey == "" {
+			return fmt.Errorf("victorops api key can't be empty without a global victorops api key")
+		}
+	case model.RecipientTypeHipChat:
+		if recipient.HipChatRecipient.RoomID == "" {
+			return fmt.Errorf("hipchat room id can't be empty")
+		}
+
+		if recipient.HipChatRecipient.AuthToken == "" && notifier.HipChatConfig.AuthToken == "" {
+			return fmt.Errorf("hipchat auth token can't be empty without a global hipchat auth token")
+		}
+	case model.RecipientTypePushover:
+		if recipient.PushoverRecipient.UserKey == "" {
+			return fmt.Errorf("pushover user key can't be empty")
+		}
+
+		if recipient.PushoverRecipient.Token == "" {
+			return fmt.Errorf("pushover token can't be empty")
+		}
+	default:
+		return fmt.Errorf("recipientTpye should be %s", strings.Join(model.RecipientTypes, "/"))
+	}
+
+	if recipient.TemplateID != "" {
+		template, err := service.GetNotificationTemplate(recipient.TemplateID)
+		if err != nil {
+			return fmt.Errorf("can not find notification template %s", recipient.TemplateID)
+		}
+		if template.Environment != recipient.Environment {
+			return fmt.Errorf("notification template %s is not in environment %s", recipient.TemplateID, recipient.Environment)
+		}
 	}
 
 	return nil
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/rancher/go-rancher/api"
+	"github.com/rancher/go-rancher/client"
+	"github.com/zionwu/monitoring-manager/config"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/sync"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+// proposedID is the id of a proposed alert or recipient without one.
+const proposedID = "proposed"
+
+func (s *Server) renderPrometheusRules(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	alerts, err := service.ListAlert(environment)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	content, err := sync.RenderPrometheusRules(alerts)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toRenderedConfigResource(apiContext, &model.RenderedConfig{
+		Resource:    client.Resource{Id: "prometheus-rules"},
+		Environment: environment,
+		Path:        config.GetConfig().PrometheusRule,
+		Content:     string(content),
+	}))
+	return http.StatusOK, nil
+}
+
+func (s *Server) diffPrometheusRules(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	_, alerts, _, errCode, err := s.getProposal(req)
+	if err != nil {
+		return errCode, err
+	}
+
+	content, err := sync.RenderPrometheusRules(alerts)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	path := config.GetConfig().PrometheusRule
+	diff, err := diffFile(path, content)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toRenderedConfigResource(apiContext, &model.RenderedConfig{
+		Resource: client.Resource{Id: "prometheus-rules"},
+		Path:     path,
+		Content:  string(content),
+		Diff:     diff,
+	}))
+	return http.StatusOK, nil
+}
+
+func (s *Server) renderAlertManagerConfig(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+	environment := req.URL.Query().Get("environment")
+
+	//the config is rendered as a whole and filtered afterwards
+	alerts, err := service.ListAlert("")
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	recipients, err := service.ListRecipient("")
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	content, rejected, err := sync.RenderAlertManagerConfig(environment, alerts, recipients)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toRenderedConfigResource(apiContext, &model.RenderedConfig{
+		Resource:    client.Resource{Id: "alertmanager-config"},
+		Environment: environment,
+		Path:        config.GetConfig().AlertManagerConfig,
+		Content:     string(content),
+		Rejected:    rejectedIn(rejected, environment, recipients),
+	}))
+	return http.StatusOK, nil
+}
+
+func (s *Server) diffAlertManagerConfig(rw http.ResponseWriter, req *http.Request) (errCode int, err error) {
+	apiContext := api.GetApiContext(req)
+
+	proposal, alerts, recipients, errCode, err := s.getProposal(req)
+	if err != nil {
+		return errCode, err
+	}
+
+	//the proposal may make Alertmanager reject the config, or the part of it
+	//the proposal belongs to
+	content, rejected, err := sync.RenderAlertManagerConfig("", alerts, recipients)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if r := proposal.Recipient; r != nil && rejected[r.Id] != nil {
+		return http.StatusBadRequest, rejected[r.Id]
+	}
+	if r := proposal.Recipient; r != nil && rejected[r.Environment] != nil {
+		return http.StatusBadRequest, rejected[r.Environment]
+	}
+	if a := proposal.Alert; a != nil && rejected[a.Environment] != nil {
+		return http.StatusBadRequest, rejected[a.Environment]
+	}
+
+	path := config.GetConfig().AlertManagerConfig
+	diff, err := diffFile(path, content)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	apiContext.Write(toRenderedConfigResource(apiContext, &model.RenderedConfig{
+		Resource: client.Resource{Id: "alertmanager-config"},
+		Path:     path,
+		Content:  string(content),
+		Diff:     diff,
+		Rejected: rejectedIn(rejected, "", recipients),
+	}))
+	return http.StatusOK, nil
+}
+
+// rejectedIn returns the errors of the recipients and environments left out
+// of the config, only those of the environment when one is given.
+func rejectedIn(rejected map[string]error, environment string, recipients []*model.Recipient) map[string]string {
+	inEnvironment := map[string]bool{environment: true}
+	for _, recipient := range recipients {
+		if recipient.Environment == environment {
+			inEnvironment[recipient.Id] = true
+		}
+	}
+
+	result := map[string]string{}
+	for id, err := range rejected {
+		if environment == "" || inEnvironment[id] {
+			result[id] = err.Error()
+		}
+	}
+	return result
+}
+
+// getProposal returns the proposal in the request along with the stored
+// alerts and recipients, with the alert or the recipient proposed in place of
+// the one with the same id. The proposal is checked like it is on create and
+// update.
+func (s *Server) getProposal(req *http.Request) (*model.RenderProposal, []*model.Alert, []*model.Recipient, int, error) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, err
+	}
+	proposal := &model.RenderProposal{}
+	if err := json.Unmarshal(data, proposal); err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, err
+	}
+	if proposal.Alert == nil && proposal.Recipient == nil {
+		return nil, nil, nil, http.StatusBadRequest, fmt.Errorf("missing alert or recipient")
+	}
+
+	alerts, err := service.ListAlert("")
+	if err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, err
+	}
+	recipients, err := service.ListRecipient("")
+	if err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, err
+	}
+
+	if alert := proposal.Alert; alert != nil {
+		if alert.Id == "" {
+			alert.Id = proposedID
+		}
+		if err := s.checkAlertParam(alert); err != nil {
+			return nil, nil, nil, paramErrorCode(err), err
+		}
+		if err := checkAlertDependencies(alert); err != nil {
+			return nil, nil, nil, http.StatusBadRequest, err
+		}
+
+		//the state is kept on update, like the alert update does
+		alert.State = model.AlertStateEnabled
+		replaced := false
+		for i, a := range alerts {
+			if a.Id == alert.Id {
+				alert.State = a.State
+				alerts[i] = alert
+				replaced = true
+			}
+		}
+		if !replaced {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	if recipient := proposal.Recipient; recipient != nil {
+		if recipient.Id == "" {
+			recipient.Id = proposedID
+		}
+		if err := s.checkRecipientParam(recipient); err != nil {
+			return nil, nil, nil, http.StatusBadRequest, err
+		}
+
+		replaced := false
+		for i, r := range recipients {
+			if r.Id == recipient.Id {
+				recipients[i] = recipient
+				replaced = true
+			}
+		}
+		if !replaced {
+			recipients = append(recipients, recipient)
+		}
+	}
+
+	return proposal, alerts, recipients, http.StatusOK, nil
+}
+
+// diffFile returns the diff from the file at path, which may not exist yet,
+// to content.
+func diffFile(path string, content []byte) (string, error) {
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return util.Diff(path, path+".proposed", current, content), nil
+}
@@ -28,6 +28,10 @@ func handleError(s *client.Schemas, t func(http.ResponseWriter, *http.Request) (
 				Msg:      err.Error(),
 				BaseType: "error",
 			}
+			if fe, ok := err.(*fieldError); ok {
+				e.Code = fe.code
+				e.Detail = fe.field
+			}
 			api.GetApiContext(req).Write(&e)
 		}
 	}))
@@ -63,6 +67,8 @@ func NewRouter(s *Server) *mux.Router {
 
 	//alert route
 	r.Methods(http.MethodGet).Path("/v1/alert").Handler(f(schemas, s.listAlerts))
+	//collection actions go before the create route, which matches any query
+	r.Methods(http.MethodPost).Path("/v1/alerts").Queries("action", "backtest").Handler(f(schemas, s.backtestAlerts))
 	r.Methods(http.MethodGet).Path("/v1/alerts").Handler(f(schemas, s.listAlerts))
 	r.Methods(http.MethodPost).Path("/v1/alert").Handler(f(schemas, s.createAlert))
 	r.Methods(http.MethodPost).Path("/v1/alerts").Handler(f(schemas, s.createAlert))
@@ -70,6 +76,43 @@ func NewRouter(s *Server) *mux.Router {
 	r.Methods(http.MethodDelete).Path("/v1/alerts/{id}").Handler(f(schemas, s.deleteAlert))
 	r.Methods(http.MethodPut).Path("/v1/alerts/{id}").Handler(f(schemas, s.updateAlert))
 
+	//maintenance window route
+	r.Methods(http.MethodGet).Path("/v1/maintenancewindows").Handler(f(schemas, s.listMaintenanceWindows))
+	r.Methods(http.MethodPost).Path("/v1/maintenancewindows").Handler(f(schemas, s.createMaintenanceWindow))
+	r.Methods(http.MethodGet).Path("/v1/maintenancewindows/{id}").Handler(f(schemas, s.getMaintenanceWindow))
+	r.Methods(http.MethodDelete).Path("/v1/maintenancewindows/{id}").Handler(f(schemas, s.deleteMaintenanceWindow))
+	r.Methods(http.MethodPut).Path("/v1/maintenancewindows/{id}").Handler(f(schemas, s.updateMaintenanceWindow))
+
+	//notification template route
+	r.Methods(http.MethodGet).Path("/v1/notificationtemplates").Handler(f(schemas, s.listNotificationTemplates))
+	r.Methods(http.MethodPost).Path("/v1/notificationtemplates").Handler(f(schemas, s.createNotificationTemplate))
+	r.Methods(http.MethodGet).Path("/v1/notificationtemplates/{id}").Handler(f(schemas, s.getNotificationTemplate))
+	r.Methods(http.MethodDelete).Path("/v1/notificationtemplates/{id}").Handler(f(schemas, s.deleteNotificationTemplate))
+	r.Methods(http.MethodPut).Path("/v1/notificationtemplates/{id}").Handler(f(schemas, s.updateNotificationTemplate))
+
+	//alert history route
+	r.Methods(http.MethodGet).Path("/v1/alerts/{id}/history").Handler(f(schemas, s.getAlertHistory))
+	r.Methods(http.MethodGet).Path("/v1/alerthistory").Handler(f(schemas, s.listAlertHistory))
+	r.Methods(http.MethodGet).Path("/v1/alertevents/{id}").Handler(f(schemas, s.getAlertEvent))
+
+	//alert instance route
+	r.Methods(http.MethodGet).Path("/v1/alerts/{id}/instances").Handler(f(schemas, s.listAlertInstances))
+	r.Methods(http.MethodGet).Path("/v1/alerts/{id}/instances/{fingerprint}").Handler(f(schemas, s.getAlertInstance))
+
+	//alert stats route
+	r.Methods(http.MethodGet).Path("/v1/alerts/{id}/stats").Handler(f(schemas, s.getAlertStats))
+	r.Methods(http.MethodGet).Path("/v1/alertstats").Handler(f(schemas, s.getEnvironmentAlertStats))
+
+	//audit log route
+	r.Methods(http.MethodGet).Path("/v1/auditlogs").Handler(f(schemas, s.listAuditLogs))
+	r.Methods(http.MethodGet).Path("/v1/auditlogs/{id}").Handler(f(schemas, s.getAuditLog))
+
+	//render route
+	r.Methods(http.MethodGet).Path("/v1/render/prometheus-rules").Handler(f(schemas, s.renderPrometheusRules))
+	r.Methods(http.MethodPost).Path("/v1/render/prometheus-rules").Handler(f(schemas, s.diffPrometheusRules))
+	r.Methods(http.MethodGet).Path("/v1/render/alertmanager-config").Handler(f(schemas, s.renderAlertManagerConfig))
+	r.Methods(http.MethodPost).Path("/v1/render/alertmanager-config").Handler(f(schemas, s.diffAlertManagerConfig))
+
 	alertConfigActions := map[string]http.Handler{
 		"update": f(schemas, s.updateAlertConfig),
 	}
@@ -82,10 +125,32 @@ func NewRouter(s *Server) *mux.Router {
 		"disable":   f(schemas, s.deactivateAlert),
 		"silence":   f(schemas, s.silenceAlert),
 		"unsilence": f(schemas, s.unsilenceAlert),
+		"backtest":  f(schemas, s.backtestAlert),
 	}
 	for name, actions := range alertActions {
 		r.Methods(http.MethodPost).Path("/v1/alerts/{id}").Queries("action", name).Handler(actions)
 	}
 
+	alertInstanceActions := map[string]http.Handler{
+		"silence": f(schemas, s.silenceAlertInstance),
+	}
+	for name, actions := range alertInstanceActions {
+		r.Methods(http.MethodPost).Path("/v1/alerts/{id}/instances/{fingerprint}").Queries("action", name).Handler(actions)
+	}
+
+	recipientActions := map[string]http.Handler{
+		"test": f(schemas, s.testRecipient),
+	}
+	for name, actions := range recipientActions {
+		r.Methods(http.MethodPost).Path("/v1/recipients/{id}").Queries("action", name).Handler(actions)
+	}
+
+	notificationTemplateActions := map[string]http.Handler{
+		"preview": f(schemas, s.previewNotificationTemplate),
+	}
+	for name, actions := range notificationTemplateActions {
+		r.Methods(http.MethodPost).Path("/v1/notificationtemplates/{id}").Queries("action", name).Handler(actions)
+	}
+
 	return r
 }
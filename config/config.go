@@ -1,6 +1,10 @@
 package config
 
-import "github.com/urfave/cli"
+import (
+	"fmt"
+
+	"github.com/urfave/cli"
+)
 
 type Config struct {
 	CattleURL        string
@@ -17,11 +21,22 @@ type Config struct {
 	ListenPort          string
 	AlertManagerURL     string
 	AlertManagerConfig  string
+
+	Store                   string
+	StoreDir                string
+	CacheRefreshIntervalSec int
+
+	FlappingThreshold int
+	FlappingWindowSec int
+
+	HistoryRetentionSec int
 }
 
 var config Config
 
-func Init(c *cli.Context) {
+// Init loads the config from the flags, and returns an error if an interval
+// is not positive.
+func Init(c *cli.Context) error {
 	config.CattleURL = c.String("cattle_url")
 	config.CattleAccessKey = c.String("cattle_access_key")
 	config.CattleSecretKey = c.String("cattle_secret_key")
@@ -35,6 +50,31 @@ func Init(c *cli.Context) {
 	config.NodeExporterPort = c.String("node_exporter_port")
 	config.RancherExporterPort = c.String("rancher_exporter_port")
 	config.ListenPort = c.String("listen_port")
+	config.Store = c.String("store")
+	config.StoreDir = c.String("store_dir")
+	config.CacheRefreshIntervalSec = c.Int("cache_refresh_interval_sec")
+	config.FlappingThreshold = c.Int("flapping_threshold")
+	config.FlappingWindowSec = c.Int("flapping_window_sec")
+	config.HistoryRetentionSec = c.Int("history_retention_sec")
+
+	if config.SyncIntervalSec <= 0 {
+		return fmt.Errorf("sync_interval_sec must be positive, got %d", config.SyncIntervalSec)
+	}
+	if config.CacheRefreshIntervalSec <= 0 {
+		return fmt.Errorf("cache_refresh_interval_sec must be positive, got %d", config.CacheRefreshIntervalSec)
+	}
+	if config.FlappingThreshold <= 0 {
+		return fmt.Errorf("flapping_threshold must be positive, got %d", config.FlappingThreshold)
+	}
+	if config.FlappingWindowSec <= 0 {
+		return fmt.Errorf("flapping_window_sec must be positive, got %d", config.FlappingWindowSec)
+	}
+	//the flapping detection counts the alert events of the window
+	if config.HistoryRetentionSec < 0 || config.HistoryRetentionSec > 0 && config.HistoryRetentionSec < config.FlappingWindowSec {
+		return fmt.Errorf("history_retention_sec must be 0 or at least flapping_window_sec, got %d", config.HistoryRetentionSec)
+	}
+
+	return nil
 }
 
 func GetConfig() Config {
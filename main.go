@@ -6,12 +6,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/handlers"
 	"github.com/urfave/cli"
 	"github.com/zionwu/monitoring-manager/api"
 	"github.com/zionwu/monitoring-manager/config"
+	"github.com/zionwu/monitoring-manager/service"
 	"github.com/zionwu/monitoring-manager/sync"
 	"golang.org/x/sync/errgroup"
 )
@@ -107,6 +109,42 @@ func main() {
 			EnvVar: "LISTEN_PORT",
 			Value:  "8888",
 		},
+		cli.StringFlag{
+			Name:   "store",
+			Usage:  "storage backend for alerts, recipients and config, cattle or file",
+			EnvVar: "STORE",
+			Value:  "cattle",
+		},
+		cli.StringFlag{
+			Name:   "store_dir",
+			Usage:  "data directory of the file storage backend",
+			EnvVar: "STORE_DIR",
+			Value:  "/var/lib/monitoring-manager",
+		},
+		cli.IntFlag{
+			Name:   "cache_refresh_interval_sec",
+			Usage:  "interval to reload the cached alerts, recipients and config from the store",
+			EnvVar: "CACHE_REFRESH_INTERVAL_SEC",
+			Value:  60,
+		},
+		cli.IntFlag{
+			Name:   "flapping_threshold",
+			Usage:  "an alert changing state more times than this within the flapping window is flapping",
+			EnvVar: "FLAPPING_THRESHOLD",
+			Value:  5,
+		},
+		cli.IntFlag{
+			Name:   "flapping_window_sec",
+			Usage:  "window used to detect flapping alerts",
+			EnvVar: "FLAPPING_WINDOW_SEC",
+			Value:  3600,
+		},
+		cli.IntFlag{
+			Name:   "history_retention_sec",
+			Usage:  "how long audit logs and alert events are kept, 0 keeps them forever",
+			EnvVar: "HISTORY_RETENTION_SEC",
+			Value:  7776000,
+		},
 	}
 
 	app.Run(os.Args)
@@ -117,7 +155,15 @@ func run(c *cli.Context) error {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
 
-	config.Init(c)
+	if err := config.Init(c); err != nil {
+		logrus.Errorf("Error while loading the config: %v", err)
+		return err
+	}
+
+	if err := service.InitStore(config.GetConfig()); err != nil {
+		logrus.Errorf("Error while initializing the store: %v", err)
+		return err
+	}
 
 	promChan := make(chan struct{}, 10)
 	alertChan := make(chan struct{}, 10)
@@ -131,12 +177,26 @@ func run(c *cli.Context) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	wg, ctx := errgroup.WithContext(ctx)
 
-	wg.Go(func() error { return sync.NewPrometheusTargetSynchronizer().Run(ctx.Done()) })
+	//targets are discovered from cattle, skip it when running standalone
+	if config.GetConfig().CattleURL != "" {
+		wg.Go(func() error { return sync.NewPrometheusTargetSynchronizer().Run(ctx.Done()) })
+	}
+	wg.Go(func() error {
+		interval := time.Second * time.Duration(config.GetConfig().CacheRefreshIntervalSec)
+		return service.RunCacheRefresher(interval, ctx.Done())
+	})
+	if config.GetConfig().HistoryRetentionSec > 0 {
+		wg.Go(func() error {
+			retention := time.Second * time.Duration(config.GetConfig().HistoryRetentionSec)
+			return service.RunHistoryPruner(retention, ctx.Done())
+		})
+	}
 	wg.Go(func() error { return sync.NewAlertStateSynchronizer().Run(ctx.Done()) })
 	wg.Go(func() error { return sync.NewAlertRouteSynchronizer(alertChan).Run(ctx.Done()) })
 	wg.Go(func() error { return sync.NewPrometheusRuleSynchronizer(promChan).Run(ctx.Done()) })
+	wg.Go(func() error { return sync.NewMaintenanceSynchronizer().Run(ctx.Done()) })
 
-	term := make(chan os.Signal)
+	term := make(chan os.Signal, 1)
 	signal.Notify(term, os.Interrupt, syscall.SIGTERM)
 
 	select {
@@ -147,7 +207,7 @@ func run(c *cli.Context) error {
 
 	cancel()
 	if err := wg.Wait(); err != nil {
-		logrus.Errorf("msg", "Unhandled error received. Exiting: %v", err)
+		logrus.Errorf("Unhandled error received. Exiting: %v", err)
 		return err
 	}
 
@@ -10,13 +10,93 @@ const (
 	AlertKind       = "alert"
 	AlertConfigKind = "alertConfig"
 	RecipientKind   = "recipient"
+	AuditLogKind    = "auditLog"
+	AlertEventKind  = "alertEvent"
+	AlertStatusKind = "alertStatus"
+
+	MaintenanceWindowKind    = "maintenanceWindow"
+	NotificationTemplateKind = "notificationTemplate"
 
 	AlertStateActive     = "active"
 	AlertStateSuppressed = "suppressed"
 	AlertStateDisabled   = "disabled"
 	AlertStateEnabled    = "enabled"
+
+	TargetTypeHost         = "host"
+	TargetTypeHostResource = "hostResource"
+	TargetTypeStack        = "stack"
+	TargetTypeService      = "service"
+	TargetTypeMetric       = "metric"
+	TargetTypeContainer    = "container"
+
+	HostResourceCPU     = "cpu"
+	HostResourceMemory  = "memory"
+	HostResourceDisk    = "disk"
+	HostResourceNetwork = "network"
+	HostResourceLoad    = "load"
+
+	ContainerRestarts      = "restarts"
+	ContainerOOMKills      = "oomKills"
+	ContainerCPUThrottling = "cpuThrottling"
+	ContainerMemoryPercent = "memoryPercent"
+
+	AlertEventFired       = "fired"
+	AlertEventSuppressed  = "suppressed"
+	AlertEventReactivated = "reactivated"
+	AlertEventResolved    = "resolved"
+
+	RecipientTypeEmail     = "email"
+	RecipientTypeWebhook   = "webhook"
+	RecipientTypeSlack     = "slack"
+	RecipientTypePagerDuty = "pagerduty"
+	RecipientTypeOpsGenie  = "opsgenie"
+	RecipientTypeVictorOps = "victorops"
+	RecipientTypeHipChat   = "hipchat"
+	RecipientTypePushover  = "pushover"
 )
 
+// TargetTypes are all the supported alert target types.
+var TargetTypes = []string{
+	TargetTypeHost,
+	TargetTypeHostResource,
+	TargetTypeStack,
+	TargetTypeService,
+	TargetTypeMetric,
+	TargetTypeContainer,
+}
+
+// HostResources are the host metrics a host resource rule can watch.
+var HostResources = []string{
+	HostResourceCPU,
+	HostResourceMemory,
+	HostResourceDisk,
+	HostResourceNetwork,
+	HostResourceLoad,
+}
+
+// ContainerMetrics are the container metrics a container rule can watch.
+var ContainerMetrics = []string{
+	ContainerRestarts,
+	ContainerOOMKills,
+	ContainerCPUThrottling,
+	ContainerMemoryPercent,
+}
+
+// Operators are the comparison operators of threshold rules.
+var Operators = []string{">", ">=", "<", "<=", "==", "!="}
+
+// RecipientTypes are all the supported recipient types.
+var RecipientTypes = []string{
+	RecipientTypeEmail,
+	RecipientTypeWebhook,
+	RecipientTypeSlack,
+	RecipientTypePagerDuty,
+	RecipientTypeOpsGenie,
+	RecipientTypeVictorOps,
+	RecipientTypeHipChat,
+	RecipientTypePushover,
+}
+
 type Error struct {
 	client.Resource
 	Status   int    `json:"status"`
@@ -28,40 +108,239 @@ type Error struct {
 
 type AlertConfig struct {
 	client.Resource
-	ResolveTimeout string          `json:"resolveTimeout"`
-	EmailConfig    EmailConfigSpec `json:"emailConfig"`
+	ResolveTimeout     string              `json:"resolveTimeout"`
+	DefaultRecipientID string              `json:"defaultRecipientId"`
+	EmailConfig        EmailConfigSpec     `json:"emailConfig"`
+	SlackConfig        SlackConfigSpec     `json:"slackConfig"`
+	PagerDutyConfig    PagerDutyConfigSpec `json:"pagerdutyConfig"`
+	OpsGenieConfig     OpsGenieConfigSpec  `json:"opsgenieConfig"`
+	VictorOpsConfig    VictorOpsConfigSpec `json:"victoropsConfig"`
+	HipChatConfig      HipChatConfigSpec   `json:"hipchatConfig"`
 }
 
 type EmailConfigSpec struct {
 	SMTPSmartHost    string `json:"smtpSmartHost"`
 	SMTPAuthUserName string `json:"smtpAuthUsername"`
 	SMTPAuthPassword string `json:"smtpAuthPassword"`
+	SMTPFrom         string `json:"smtpFrom"`
+	SMTPAuthIdentity string `json:"smtpAuthIdentity"`
+	SMTPAuthSecret   string `json:"smtpAuthSecret"`
+	SMTPRequireTLS   bool   `json:"smtpRequireTls"`
+}
+
+type SlackConfigSpec struct {
+	APIURL string `json:"apiUrl"`
+}
+
+type PagerDutyConfigSpec struct {
+	URL string `json:"url"`
+}
+
+// OpsGenieConfigSpec holds the OpsGenie settings shared by all recipients.
+// Alertmanager has no global API key, so it is set on every OpsGenie
+// receiver without its own key.
+type OpsGenieConfigSpec struct {
+	APIHost string `json:"apiHost"`
+	APIKey  string `json:"apiKey"`
+}
+
+type VictorOpsConfigSpec struct {
+	APIURL string `json:"apiUrl"`
+	APIKey string `json:"apiKey"`
+}
+
+type HipChatConfigSpec struct {
+	URL       string `json:"url"`
+	AuthToken string `json:"authToken"`
 }
 
 type Alert struct {
 	client.Resource
+	ResourceVersion int64 `json:"resourceVersion"`
+
 	Description string `json:"description"`
 	State       string `json:"state"`
 	Severity    string `json:"severity"`
 	TargetType  string `json:"targetType"`
 	TargetID    string `json:"targetId"`
 
+	TargetSelector TargetSelectorSpec `json:"targetSelector"`
+
 	HostRule    CommonHealthRule `json:"hostRule"`
 	ServiceRule CommonHealthRule `json:"serviceRule"`
 	StackRule   CommonHealthRule `json:"stackRule"`
 
+	HostResourceRule HostResourceRuleSpec `json:"hostResourceRule"`
+	ContainerRule    ContainerRuleSpec    `json:"containerRule"`
+
 	AdvancedOptions AdvancedOptionsSpec `json:"advancedOptions"`
 	MetricRule      MetricRuleSpec      `json:"metricRule"`
 	Environment     string              `json:"environment"`
 	RecipientID     string              `json:"recipientId"`
-	StartsAt        time.Time           `json:"startsAt,omitempty"`
-	EndsAt          time.Time           `json:"endsAt,omitempty"`
+	RecipientIDs    []string            `json:"recipientIds"`
+	Flapping        bool                `json:"flapping"`
+	Silence         AlertSilenceSpec    `json:"silence"`
+
+	//the firing times, instances and maintenance window are recorded on the
+	//status of the alert, they are filled in from it when the alert is read
+	//through the API
+	StartsAt            time.Time        `json:"startsAt,omitempty"`
+	EndsAt              time.Time        `json:"endsAt,omitempty"`
+	Instances           []*AlertInstance `json:"instances"`
+	MaintenanceWindowID string           `json:"maintenanceWindowId"`
+
+	//set while the target selector selects no target, the rule of the
+	//alert then matches nothing
+	SelectorEmpty bool `json:"selectorEmpty"`
+
+	//the alert is inhibited while an alert it depends on fires. With
+	//dependency labels, only its series sharing the values of those labels
+	//with a firing series are, e.g. host_id for the alerts of a host down
+	DependsOn        []string `json:"dependsOn"`
+	DependencyLabels []string `json:"dependencyLabels"`
+
+	//labels and annotations are added to the rule of the alert, annotations
+	//may use the $labels and $value of the series
+	Summary     string            `json:"summary"`
+	RunbookURL  string            `json:"runbookUrl"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// AlertStatus is the status of an alert observed by the synchronizers. It is
+// kept apart from the alert, under the id of the alert, so that recording it
+// does not bump the resource version of the alert.
+type AlertStatus struct {
+	client.Resource
+	AlertID             string           `json:"alertId"`
+	Environment         string           `json:"environment"`
+	SelectorEmpty       bool             `json:"selectorEmpty"`
+	StartsAt            time.Time        `json:"startsAt,omitempty"`
+	EndsAt              time.Time        `json:"endsAt,omitempty"`
+	Instances           []*AlertInstance `json:"instances"`
+	MaintenanceWindowID string           `json:"maintenanceWindowId"`
+}
+
+// AlertSilenceSpec describes the Alertmanager silence suppressing an alert.
+// It is empty while the alert is not silenced.
+type AlertSilenceSpec struct {
+	SilenceID string    `json:"silenceId"`
+	Comment   string    `json:"comment"`
+	CreatedBy string    `json:"createdBy"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+// SilenceInput is the input of the silence actions. The silence lasts for
+// the duration or until the end time, forever when neither is given.
+type SilenceInput struct {
+	Duration string    `json:"duration"`
+	EndsAt   time.Time `json:"endsAt,omitempty"`
+	Comment  string    `json:"comment"`
+}
+
+// BacktestInput is the input of the backtest actions. The alert is evaluated
+// from start to end, the last day by default, at every step, a minute by
+// default. The collection action backtests the alert of the input.
+type BacktestInput struct {
+	Alert *Alert    `json:"alert,omitempty"`
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+	Step  string    `json:"step"`
+}
+
+// AlertBacktest is the series the rule of an alert would have fired for over
+// a time range, with the periods each would have fired.
+type AlertBacktest struct {
+	client.Resource
+	AlertID        string            `json:"alertId,omitempty"`
+	Expr           string            `json:"expr"`
+	HoldDuration   string            `json:"holdDuration"`
+	Start          time.Time         `json:"start"`
+	End            time.Time         `json:"end"`
+	Step           string            `json:"step"`
+	FiringCount    int               `json:"firingCount"`
+	TotalFiringSec int64             `json:"totalFiringSec"`
+	Series         []*BacktestSeries `json:"series"`
+}
+
+type BacktestSeries struct {
+	Labels  map[string]string `json:"labels"`
+	Periods []*BacktestPeriod `json:"periods"`
+}
+
+// BacktestPeriod is a period a series would have fired. A period lasting
+// until the end of the range ends at the end of it.
+type BacktestPeriod struct {
+	StartsAt time.Time `json:"startsAt"`
+	EndsAt   time.Time `json:"endsAt"`
+}
+
+// AlertInstance is one firing series of an alert, identified by the
+// fingerprint of its label set in Alertmanager.
+type AlertInstance struct {
+	client.Resource
+	AlertID     string            `json:"alertId"`
+	Fingerprint string            `json:"fingerprint"`
+	Labels      map[string]string `json:"labels"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Silenced    bool              `json:"silenced"`
+	SilencedBy  []string          `json:"silencedBy"`
+}
+
+// GetRecipientIDs returns the recipients of the alert, falling back to the
+// single recipientId of alerts created before recipientIds existed.
+func (a *Alert) GetRecipientIDs() []string {
+	if len(a.RecipientIDs) == 0 && a.RecipientID != "" {
+		return []string{a.RecipientID}
+	}
+	return a.RecipientIDs
+}
+
+// TargetSelectorSpec selects many targets of an alert in place of its
+// targetId: the services of a stack, or the hosts or services carrying all
+// the labels. Each selected target fires on its own.
+type TargetSelectorSpec struct {
+	StackID string            `json:"stackId"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// IsEmpty reports whether the selector selects nothing, in which case the
+// alert is bound to its targetId.
+func (s TargetSelectorSpec) IsEmpty() bool {
+	return s.StackID == "" && len(s.Labels) == 0
 }
 
 type CommonHealthRule struct {
 	HoldDuration string `json:"holdDuration, omitempty"`
 }
 
+// HostResourceRuleSpec fires when a host metric compares to the threshold
+// for the hold duration. The threshold of cpu, memory and disk is a usage
+// percentage, network is in bytes per second and load is the 1m load average.
+type HostResourceRuleSpec struct {
+	Metric       string  `json:"metric"`
+	Operator     string  `json:"operator"`
+	Threshold    float64 `json:"threshold"`
+	HoldDuration string  `json:"holdDuration"`
+}
+
+// ContainerRuleSpec fires when a metric of the selected containers compares
+// to the threshold for the hold duration. Containers are selected by the
+// container id in the alert targetId, by service or by container labels.
+// Restarts and OOM kills are counted over the window, CPU throttling is the
+// percentage of throttled CFS periods and memory is a percentage of the limit.
+type ContainerRuleSpec struct {
+	Metric       string            `json:"metric"`
+	Operator     string            `json:"operator"`
+	Threshold    float64           `json:"threshold"`
+	HoldDuration string            `json:"holdDuration"`
+	Window       string            `json:"window"`
+	ServiceID    string            `json:"serviceId"`
+	Labels       map[string]string `json:"labels"`
+}
+
 type MetricRuleSpec struct {
 	Expr         string `json:"expr, omitempty"`
 	HoldDuration string `json:"holdDuration, omitempty"`
@@ -74,18 +353,191 @@ type AdvancedOptionsSpec struct {
 
 type Recipient struct {
 	client.Resource
+	ResourceVersion int64 `json:"resourceVersion"`
+
 	Environment   string `json:"environment"`
 	RecipientType string `json:"recipientType"`
 
-	EmailRecipient   EmailRecipientSpec   `json:"emailRecipient"`
-	WebhookRecipient WebhookRecipientSpec `json:"webhookRecipient"`
+	EmailRecipient     EmailRecipientSpec     `json:"emailRecipient"`
+	WebhookRecipient   WebhookRecipientSpec   `json:"webhookRecipient"`
+	SlackRecipient     SlackRecipientSpec     `json:"slackRecipient"`
+	PagerDutyRecipient PagerDutyRecipientSpec `json:"pagerdutyRecipient"`
+	OpsGenieRecipient  OpsGenieRecipientSpec  `json:"opsgenieRecipient"`
+	VictorOpsRecipient VictorOpsRecipientSpec `json:"victoropsRecipient"`
+	HipChatRecipient   HipChatRecipientSpec   `json:"hipchatRecipient"`
+	PushoverRecipient  PushoverRecipientSpec  `json:"pushoverRecipient"`
+
+	TemplateID string `json:"templateId"`
+	//unset keeps the default of the integration
+	SendResolved *bool `json:"sendResolved,omitempty"`
 }
 
+// WebhookRecipientSpec posts notifications to the url, with basic auth when
+// a username is set. The webhooks of Alertmanager 0.11 have no other HTTP
+// settings, the credentials are passed in the url.
 type WebhookRecipientSpec struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
+
+	BasicAuthUsername string `json:"basicAuthUsername"`
+	BasicAuthPassword string `json:"basicAuthPassword"`
 }
 
 type EmailRecipientSpec struct {
 	Address string `json:"address"`
 }
+
+// SlackRecipientSpec posts to a channel, using the global Slack API URL
+// unless the recipient has its own incoming webhook.
+type SlackRecipientSpec struct {
+	Channel string `json:"channel"`
+	APIURL  string `json:"apiUrl"`
+}
+
+type PagerDutyRecipientSpec struct {
+	ServiceKey string `json:"serviceKey"`
+}
+
+type OpsGenieRecipientSpec struct {
+	APIKey string `json:"apiKey"`
+	Teams  string `json:"teams"`
+	Tags   string `json:"tags"`
+}
+
+// VictorOpsRecipientSpec uses the global VictorOps API key unless the
+// recipient has its own.
+type VictorOpsRecipientSpec struct {
+	APIKey     string `json:"apiKey"`
+	RoutingKey string `json:"routingKey"`
+}
+
+// HipChatRecipientSpec uses the global HipChat auth token unless the
+// recipient has its own.
+type HipChatRecipientSpec struct {
+	RoomID    string `json:"roomId"`
+	AuthToken string `json:"authToken"`
+	Notify    bool   `json:"notify"`
+}
+
+type PushoverRecipientSpec struct {
+	UserKey string `json:"userKey"`
+	Token   string `json:"token"`
+}
+
+// MaintenanceWindow silences the alerts of an environment, or only those of
+// some hosts, stacks or services, for the duration. It happens once at
+// startsAt, or on every start of the cron schedule.
+type MaintenanceWindow struct {
+	client.Resource
+	ResourceVersion int64 `json:"resourceVersion"`
+
+	Environment string    `json:"environment"`
+	Description string    `json:"description"`
+	TargetType  string    `json:"targetType"`
+	TargetIDs   []string  `json:"targetIds"`
+	StartsAt    time.Time `json:"startsAt,omitempty"`
+	Schedule    string    `json:"schedule"`
+	Timezone    string    `json:"timezone"`
+	Duration    string    `json:"duration"`
+
+	Active     bool      `json:"active"`
+	ActiveFrom time.Time `json:"activeFrom,omitempty"`
+	ActiveTo   time.Time `json:"activeTo,omitempty"`
+	SilenceIDs []string  `json:"silenceIds"`
+}
+
+// NotificationTemplate customizes the notifications sent to the recipients
+// using it. Its fields are Alertmanager Go templates executed with the data
+// of a notification: the subject, text and HTML body of emails, and the title
+// and text of chat integrations. Empty fields keep the default notification.
+// The resolved email fields replace the email fields in the notifications of
+// resolved alerts, sent to recipients with sendResolved.
+type NotificationTemplate struct {
+	client.Resource
+	ResourceVersion int64 `json:"resourceVersion"`
+
+	Environment          string `json:"environment"`
+	Name                 string `json:"name"`
+	EmailSubject         string `json:"emailSubject"`
+	EmailText            string `json:"emailText"`
+	EmailHTML            string `json:"emailHtml"`
+	EmailResolvedSubject string `json:"emailResolvedSubject"`
+	EmailResolvedText    string `json:"emailResolvedText"`
+	EmailResolvedHTML    string `json:"emailResolvedHtml"`
+	Title                string `json:"title"`
+	Text                 string `json:"text"`
+}
+
+// NotificationPreview is a notification template rendered against a sample
+// alert, firing or resolved for the resolved email fields.
+type NotificationPreview struct {
+	client.Resource
+	EmailSubject         string `json:"emailSubject"`
+	EmailText            string `json:"emailText"`
+	EmailHTML            string `json:"emailHtml"`
+	EmailResolvedSubject string `json:"emailResolvedSubject"`
+	EmailResolvedText    string `json:"emailResolvedText"`
+	EmailResolvedHTML    string `json:"emailResolvedHtml"`
+	Title                string `json:"title"`
+	Text                 string `json:"text"`
+}
+
+// RenderedConfig is a configuration file as the manager would write it from
+// the store. Rendered with a proposal, it has the diff from the written file.
+type RenderedConfig struct {
+	client.Resource
+	Environment string `json:"environment,omitempty"`
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Diff        string `json:"diff,omitempty"`
+
+	//the errors of the recipients and environments left out of the config
+	//for Alertmanager to accept it, by id
+	Rejected map[string]string `json:"rejected,omitempty"`
+}
+
+// RenderProposal is an alert or a recipient to render the configuration with,
+// in place of the stored one with the same id. Without an id it is added.
+type RenderProposal struct {
+	Alert     *Alert     `json:"alert,omitempty"`
+	Recipient *Recipient `json:"recipient,omitempty"`
+}
+
+type AuditLog struct {
+	client.Resource
+	Environment  string    `json:"environment"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   string    `json:"resourceId"`
+	Action       string    `json:"action"`
+	Actor        string    `json:"actor"`
+	Timestamp    time.Time `json:"timestamp"`
+	Before       string    `json:"before,omitempty"`
+	After        string    `json:"after,omitempty"`
+}
+
+type AlertEvent struct {
+	client.Resource
+	AlertID     string    `json:"alertId"`
+	Environment string    `json:"environment"`
+	Description string    `json:"description"`
+	Event       string    `json:"event"`
+	FromState   string    `json:"fromState"`
+	ToState     string    `json:"toState"`
+	Timestamp   time.Time `json:"timestamp"`
+	StartsAt    time.Time `json:"startsAt,omitempty"`
+}
+
+type AlertStats struct {
+	client.Resource
+	AlertID                  string   `json:"alertId,omitempty"`
+	Environment              string   `json:"environment"`
+	Window                   string   `json:"window"`
+	FiringCount              int      `json:"firingCount"`
+	Transitions              int      `json:"transitions"`
+	TotalActiveSec           int64    `json:"totalActiveSec"`
+	MeanActiveSec            int64    `json:"meanActiveSec"`
+	MeanTimeToResolveSec     int64    `json:"meanTimeToResolveSec"`
+	MeanTimeToAcknowledgeSec int64    `json:"meanTimeToAcknowledgeSec"`
+	Flapping                 bool     `json:"flapping"`
+	FlappingAlerts           []string `json:"flappingAlerts,omitempty"`
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+// BacktestAlert evaluates the expression of an alerting rule from start to end
+// at every step through Prometheus, and returns the periods its series would
+// have fired with the hold duration of the rule.
+func BacktestAlert(expr string, hold time.Duration, start, end time.Time, step time.Duration) (*model.AlertBacktest, error) {
+	matrix, err := util.QueryRange(expr, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	backtest := &model.AlertBacktest{
+		Expr:         expr,
+		HoldDuration: prommodel.Duration(hold).String(),
+		Start:        start,
+		End:          end,
+		Step:         prommodel.Duration(step).String(),
+		Series:       []*model.BacktestSeries{},
+	}
+	for _, stream := range matrix {
+		periods := firingPeriods(stream.Values, hold, step, end)
+		if len(periods) == 0 {
+			continue
+		}
+
+		labels := map[string]string{}
+		for name, value := range stream.Metric {
+			labels[string(name)] = string(value)
+		}
+		backtest.Series = append(backtest.Series, &model.BacktestSeries{
+			Labels:  labels,
+			Periods: periods,
+		})
+
+		backtest.FiringCount += len(periods)
+		for _, p := range periods {
+			backtest.TotalFiringSec += int64(p.EndsAt.Sub(p.StartsAt).Seconds())
+		}
+	}
+
+	return backtest, nil
+}
+
+// firingPeriods returns the periods a series with the samples would have
+// fired, the way Prometheus evaluates a rule at every step: the series is
+// pending from the first step it is returned, fires at the first step it has
+// been pending for the hold duration and is resolved at the first step it is
+// not returned. A series already pending at the start of the range is pending
+// from there.
+func firingPeriods(samples []prommodel.SamplePair, hold, step time.Duration, end time.Time) []*model.BacktestPeriod {
+	for len(samples) > 0 && samples[len(samples)-1].Timestamp.Time().After(end) {
+		samples = samples[:len(samples)-1]
+	}
+
+	periods := []*model.BacktestPeriod{}
+	for i := 0; i < len(samples); {
+		//the samples of consecutive steps
+		j := i
+		for j+1 < len(samples) && samples[j+1].Timestamp.Sub(samples[j].Timestamp) <= step {
+			j++
+		}
+
+		for k := i; k <= j; k++ {
+			if samples[k].Timestamp.Sub(samples[i].Timestamp) < hold {
+				continue
+			}
+			endsAt := samples[j].Timestamp.Time().Add(step)
+			if endsAt.After(end) {
+				endsAt = end
+			}
+			periods = append(periods, &model.BacktestPeriod{
+				StartsAt: samples[k].Timestamp.Time(),
+				EndsAt:   endsAt,
+			})
+			break
+		}
+
+		i = j + 1
+	}
+
+	return periods
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+)
+
+func TestFiringPeriods(t *testing.T) {
+	start := time.Date(2018, 3, 14, 10, 0, 0, 0, time.UTC)
+	step := time.Minute
+	//at returns the time of the nth step of the range
+	at := func(n int) time.Time {
+		return start.Add(time.Duration(n) * step)
+	}
+	samples := func(steps ...int) []prommodel.SamplePair {
+		pairs := []prommodel.SamplePair{}
+		for _, n := range steps {
+			pairs = append(pairs, prommodel.SamplePair{Timestamp: prommodel.TimeFromUnixNano(at(n).UnixNano()), Value: 1})
+		}
+		return pairs
+	}
+
+	for _, test := range []struct {
+		name    string
+		samples []prommodel.SamplePair
+		hold    time.Duration
+		end     time.Time
+		want    [][2]time.Time
+	}{
+		{
+			name:    "no samples",
+			samples: samples(),
+			end:     at(10),
+			want:    [][2]time.Time{},
+		},
+		{
+			name:    "fires at once without hold",
+			samples: samples(2, 3, 4),
+			end:     at(10),
+			want:    [][2]time.Time{{at(2), at(5)}},
+		},
+		{
+			name:    "fires after the hold duration",
+			samples: samples(2, 3, 4, 5),
+			hold:    2 * step,
+			end:     at(10),
+			want:    [][2]time.Time{{at(4), at(6)}},
+		},
+		{
+			name:    "pending shorter than the hold duration",
+			samples: samples(2, 3),
+			hold:    2 * step,
+			end:     at(10),
+			want:    [][2]time.Time{},
+		},
+		{
+			name:    "a gap resolves and restarts the hold duration",
+			samples: samples(1, 2, 3, 5, 6, 8),
+			hold:    step,
+			end:     at(10),
+			want:    [][2]time.Time{{at(2), at(4)}, {at(6), at(7)}},
+		},
+		{
+			name:    "still firing at the end of the range",
+			samples: samples(7, 8, 9, 10),
+			end:     at(10),
+			want:    [][2]time.Time{{at(7), at(10)}},
+		},
+		{
+			name:    "samples after the end of the range are left out",
+			samples: samples(8, 9, 10, 11, 12),
+			hold:    3 * step,
+			end:     at(10),
+			want:    [][2]time.Time{},
+		},
+	} {
+		periods := firingPeriods(test.samples, test.hold, step, test.end)
+		if len(periods) != len(test.want) {
+			t.Errorf("%s: got %d periods, want %d", test.name, len(periods), len(test.want))
+			continue
+		}
+		for i, p := range periods {
+			if !p.StartsAt.Equal(test.want[i][0]) || !p.EndsAt.Equal(test.want[i][1]) {
+				t.Errorf("%s: period %d is %v - %v, want %v - %v", test.name, i, p.StartsAt, p.EndsAt, test.want[i][0], test.want[i][1])
+			}
+		}
+	}
+}
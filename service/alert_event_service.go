@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/sluu99/uuid"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// ListAlertEvent returns the state transitions of the alerts in environment,
+// or of the single alert when alertID is set, newest first.
+func ListAlertEvent(environment string, alertID string) ([]*model.AlertEvent, error) {
+	objs, err := cache.list(model.AlertEventKind, environment)
+	if err != nil {
+		logrus.Errorf("fail to list alert event,err:%v", err)
+		return nil, err
+	}
+
+	var events []*model.AlertEvent
+	for _, obj := range objs {
+		e := &model.AlertEvent{}
+		json.Unmarshal(obj.Data, e)
+		if alertID != "" && e.AlertID != alertID {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.After(events[j].Timestamp) })
+
+	return events, nil
+}
+
+func GetAlertEvent(id string) (*model.AlertEvent, error) {
+	obj, err := cache.get(model.AlertEventKind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &model.AlertEvent{}
+	err = json.Unmarshal(obj.Data, event)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// RecordAlertTransition persists the transition of alert from state from to
+// its current state. Transitions that are not part of the firing timeline,
+// such as enabling or disabling, are ignored.
+func RecordAlertTransition(alert *model.Alert, from string, startsAt time.Time) error {
+	event := TransitionEvent(from, alert.State)
+	if event == "" {
+		return nil
+	}
+
+	e := &model.AlertEvent{
+		AlertID:     alert.Id,
+		Environment: alert.Environment,
+		Description: alert.Description,
+		Event:       event,
+		FromState:   from,
+		ToState:     alert.State,
+		Timestamp:   time.Now(),
+		StartsAt:    startsAt,
+	}
+	e.Id = uuid.Rand().Hex()
+
+	b, err := json.Marshal(*e)
+	if err != nil {
+		return err
+	}
+
+	return createObject(&Object{
+		Kind: model.AlertEventKind,
+		ID:   e.Id,
+		Data: b,
+	})
+}
+
+// TransitionEvent returns the timeline event of a state change, or an empty
+// string if the change is not part of the firing timeline.
+func TransitionEvent(from string, to string) string {
+	switch {
+	case from == model.AlertStateEnabled && to == model.AlertStateActive:
+		return model.AlertEventFired
+	case from == model.AlertStateEnabled && to == model.AlertStateSuppressed:
+		return model.AlertEventFired
+	case from == model.AlertStateActive && to == model.AlertStateSuppressed:
+		return model.AlertEventSuppressed
+	case from == model.AlertStateSuppressed && to == model.AlertStateActive:
+		return model.AlertEventReactivated
+	case (from == model.AlertStateActive || from == model.AlertStateSuppressed) && to == model.AlertStateEnabled:
+		return model.AlertEventResolved
+	}
+
+	return ""
+}
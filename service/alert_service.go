@@ -2,41 +2,38 @@ package service
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/Sirupsen/logrus"
-	v2client "github.com/rancher/go-rancher/v2"
 	"github.com/sluu99/uuid"
 	"github.com/zionwu/monitoring-manager/model"
 )
 
 func ListAlert(environment string) ([]*model.Alert, error) {
-	geObjList, err := paginateGenericObjects("alert")
+	objs, err := cache.list(model.AlertKind, environment)
 	if err != nil {
 		logrus.Errorf("fail to list alert,err:%v", err)
 		return nil, err
 	}
 
 	var alerts []*model.Alert
-	for _, gobj := range geObjList {
-		b := []byte(gobj.ResourceData["data"].(string))
+	for _, obj := range objs {
 		a := &model.Alert{}
-		json.Unmarshal(b, a)
-		if environment == "" || a.Environment == environment {
-			alerts = append(alerts, a)
-		}
+		json.Unmarshal(obj.Data, a)
+		alerts = append(alerts, a)
 	}
 
 	return alerts, nil
 }
 
 func GetAlert(id string) (*model.Alert, error) {
-	data, err := getGenericObjectById("alert", id)
+	obj, err := cache.get(model.AlertKind, id)
 	if err != nil {
 		return nil, err
 	}
 
 	alert := &model.Alert{}
-	err = json.Unmarshal([]byte(data.ResourceData["data"].(string)), alert)
+	err = json.Unmarshal(obj.Data, alert)
 	if err != nil {
 		return nil, err
 	}
@@ -45,87 +42,57 @@ func GetAlert(id string) (*model.Alert, error) {
 }
 
 func CreateAlert(alert *model.Alert) error {
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
 	alert.Id = uuid.Rand().Hex()
-	b, err := json.Marshal(*alert)
+	alert.ResourceVersion = 1
+	b, err := alertData(alert)
 	if err != nil {
 		return err
 	}
-	resourceData := map[string]interface{}{
-		"data": string(b),
-	}
 
-	_, err = rclient.GenericObject.Create(&v2client.GenericObject{
-		Name:         alert.Id,
-		Key:          alert.Id,
-		ResourceData: resourceData,
-		Kind:         "alert",
+	return createObject(&Object{
+		Kind: model.AlertKind,
+		ID:   alert.Id,
+		Data: b,
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
 func DeleteAlert(id string) error {
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
-	data, err := getGenericObjectById("alert", id)
-	if err != nil {
-		logrus.Errorf("Error while getting alert", err)
-		return err
-	}
-
-	alert := &model.Alert{}
-	err = json.Unmarshal([]byte(data.ResourceData["data"].(string)), alert)
-	if err != nil {
-		return err
-	}
-
-	if err = rclient.GenericObject.Delete(&data); err != nil {
-		return err
-	}
-
-	return nil
+	return deleteObject(model.AlertKind, id)
 }
 
+// UpdateAlert saves alert if it is still at its resource version, otherwise
+// ErrConflict is returned. On success the version is bumped in place.
 func UpdateAlert(alert *model.Alert) error {
-
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
-	alertGO, err := getGenericObjectById("alert", alert.Id)
-	if err != nil {
-		return err
-	}
-
-	b, err := json.Marshal(*alert)
+	version := alert.ResourceVersion
+	alert.ResourceVersion++
+	b, err := alertData(alert)
 	if err != nil {
+		alert.ResourceVersion = version
 		return err
 	}
-	resourceData := map[string]interface{}{
-		"data": string(b),
-	}
 
-	_, err = rclient.GenericObject.Update(&alertGO, &v2client.GenericObject{
-		Name:         alert.Id,
-		Key:          alert.Id,
-		ResourceData: resourceData,
-		Kind:         "alert",
-	})
+	err = compareAndSwap(&Object{
+		Kind: model.AlertKind,
+		ID:   alert.Id,
+		Data: b,
+	}, version)
 	if err != nil {
+		alert.ResourceVersion = version
 		return err
 	}
 
 	return nil
 }
+
+// alertData returns the JSON of the alert as it is stored, without the fields
+// recorded on its status.
+func alertData(alert *model.Alert) ([]byte, error) {
+	stored := *alert
+	stored.SelectorEmpty = false
+	stored.StartsAt = time.Time{}
+	stored.EndsAt = time.Time{}
+	stored.Instances = nil
+	stored.MaintenanceWindowID = ""
+
+	return json.Marshal(stored)
+}
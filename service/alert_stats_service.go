@@ -0,0 +1,189 @@
+package service
+
+import (
+	"sort"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"github.com/zionwu/monitoring-manager/config"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// statsAccumulator sums up the firing episodes of one or more alerts seen in
+// a window.
+type statsAccumulator struct {
+	since time.Time
+	now   time.Time
+
+	firing      int
+	transitions int
+	episodes    int
+	active      time.Duration
+	resolved    int
+	resolveTime time.Duration
+	acked       int
+	ackTime     time.Duration
+}
+
+// GetAlertStats computes the statistics of alert over the window ending now.
+func GetAlertStats(alert *model.Alert, window time.Duration) (*model.AlertStats, error) {
+	events, err := ListAlertEvent(alert.Environment, alert.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	acc := &statsAccumulator{since: now.Add(-window), now: now}
+	acc.add(events)
+
+	stats := acc.toStats(window)
+	stats.AlertID = alert.Id
+	stats.Environment = alert.Environment
+	stats.Flapping = alert.Flapping
+
+	return stats, nil
+}
+
+// GetEnvironmentAlertStats computes the statistics of all the alerts in
+// environment over the window ending now.
+func GetEnvironmentAlertStats(environment string, window time.Duration) (*model.AlertStats, error) {
+	events, err := ListAlertEvent(environment, "")
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := ListAlert(environment)
+	if err != nil {
+		return nil, err
+	}
+
+	byAlert := map[string][]*model.AlertEvent{}
+	for _, e := range events {
+		byAlert[e.AlertID] = append(byAlert[e.AlertID], e)
+	}
+
+	now := time.Now()
+	acc := &statsAccumulator{since: now.Add(-window), now: now}
+	for _, alertEvents := range byAlert {
+		acc.add(alertEvents)
+	}
+
+	stats := acc.toStats(window)
+	stats.Environment = environment
+	for _, alert := range alerts {
+		if alert.Flapping {
+			stats.FlappingAlerts = append(stats.FlappingAlerts, alert.Id)
+		}
+	}
+	stats.Flapping = len(stats.FlappingAlerts) > 0
+
+	return stats, nil
+}
+
+// IsAlertFlapping reports whether alert changed state more times than the
+// configured threshold within the flapping window, counting pending
+// transitions that are not recorded yet.
+func IsAlertFlapping(alert *model.Alert, pending int) (bool, error) {
+	c := config.GetConfig()
+	events, err := ListAlertEvent(alert.Environment, alert.Id)
+	if err != nil {
+		return false, err
+	}
+
+	since := time.Now().Add(-time.Second * time.Duration(c.FlappingWindowSec))
+	transitions := pending
+	for _, e := range events {
+		if !e.Timestamp.Before(since) {
+			transitions++
+		}
+	}
+
+	return transitions > c.FlappingThreshold, nil
+}
+
+// add walks through the events of a single alert.
+func (a *statsAccumulator) add(events []*model.AlertEvent) {
+	sorted := make([]*model.AlertEvent, len(events))
+	copy(sorted, events)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	var start time.Time
+	firing, acked := false, false
+	for _, e := range sorted {
+		inWindow := !e.Timestamp.Before(a.since)
+		if inWindow {
+			a.transitions++
+		}
+
+		switch e.Event {
+		case model.AlertEventFired:
+			firing = true
+			start = e.Timestamp
+			if !e.StartsAt.IsZero() && e.StartsAt.Before(start) {
+				start = e.StartsAt
+			}
+			//fired while already silenced, nobody had to acknowledge it
+			acked = e.ToState == model.AlertStateSuppressed
+			if inWindow {
+				a.firing++
+			}
+		case model.AlertEventSuppressed:
+			if firing && !acked {
+				acked = true
+				if inWindow {
+					a.acked++
+					a.ackTime += e.Timestamp.Sub(start)
+				}
+			}
+		case model.AlertEventResolved:
+			if firing {
+				a.addActive(start, e.Timestamp)
+				if inWindow {
+					a.resolved++
+					a.resolveTime += e.Timestamp.Sub(start)
+				}
+			}
+			firing = false
+		}
+	}
+
+	if firing {
+		a.addActive(start, a.now)
+	}
+}
+
+// addActive adds the part of a firing episode that falls in the window.
+func (a *statsAccumulator) addActive(start time.Time, end time.Time) {
+	if start.Before(a.since) {
+		start = a.since
+	}
+	if end.After(a.now) {
+		end = a.now
+	}
+	if !end.After(start) {
+		return
+	}
+
+	a.episodes++
+	a.active += end.Sub(start)
+}
+
+func (a *statsAccumulator) toStats(window time.Duration) *model.AlertStats {
+	stats := &model.AlertStats{
+		Window:         prommodel.Duration(window).String(),
+		FiringCount:    a.firing,
+		Transitions:    a.transitions,
+		TotalActiveSec: int64(a.active.Seconds()),
+	}
+	if a.episodes > 0 {
+		stats.MeanActiveSec = int64(a.active.Seconds()) / int64(a.episodes)
+	}
+	if a.resolved > 0 {
+		stats.MeanTimeToResolveSec = int64(a.resolveTime.Seconds()) / int64(a.resolved)
+	}
+	if a.acked > 0 {
+		stats.MeanTimeToAcknowledgeSec = int64(a.ackTime.Seconds()) / int64(a.acked)
+	}
+
+	return stats
+}
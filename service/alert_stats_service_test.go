@@ -0,0 +1,142 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+func TestStatsAccumulator(t *testing.T) {
+	now := time.Date(2018, 3, 14, 12, 0, 0, 0, time.UTC)
+	//ago returns the time the minutes before now
+	ago := func(minutes int) time.Time {
+		return now.Add(-time.Duration(minutes) * time.Minute)
+	}
+	fired := func(minutes int) *model.AlertEvent {
+		return &model.AlertEvent{Event: model.AlertEventFired, ToState: model.AlertStateActive, Timestamp: ago(minutes)}
+	}
+	suppressed := func(minutes int) *model.AlertEvent {
+		return &model.AlertEvent{Event: model.AlertEventSuppressed, ToState: model.AlertStateSuppressed, Timestamp: ago(minutes)}
+	}
+	resolved := func(minutes int) *model.AlertEvent {
+		return &model.AlertEvent{Event: model.AlertEventResolved, Timestamp: ago(minutes)}
+	}
+
+	for _, test := range []struct {
+		name   string
+		alerts [][]*model.AlertEvent
+		want   model.AlertStats
+	}{
+		{
+			name:   "no events",
+			alerts: [][]*model.AlertEvent{{}},
+			want:   model.AlertStats{},
+		},
+		{
+			name:   "acknowledged and resolved",
+			alerts: [][]*model.AlertEvent{{fired(50), suppressed(40), resolved(20)}},
+			want: model.AlertStats{
+				FiringCount:              1,
+				Transitions:              3,
+				TotalActiveSec:           30 * 60,
+				MeanActiveSec:            30 * 60,
+				MeanTimeToResolveSec:     30 * 60,
+				MeanTimeToAcknowledgeSec: 10 * 60,
+			},
+		},
+		{
+			name:   "events out of order",
+			alerts: [][]*model.AlertEvent{{resolved(20), fired(50), suppressed(40)}},
+			want: model.AlertStats{
+				FiringCount:              1,
+				Transitions:              3,
+				TotalActiveSec:           30 * 60,
+				MeanActiveSec:            30 * 60,
+				MeanTimeToResolveSec:     30 * 60,
+				MeanTimeToAcknowledgeSec: 10 * 60,
+			},
+		},
+		{
+			//only the part in the window is active, the whole episode is resolved
+			name:   "fired before the window",
+			alerts: [][]*model.AlertEvent{{fired(90), resolved(30)}},
+			want: model.AlertStats{
+				Transitions:          1,
+				TotalActiveSec:       30 * 60,
+				MeanActiveSec:        30 * 60,
+				MeanTimeToResolveSec: 60 * 60,
+			},
+		},
+		{
+			name:   "resolved before the window",
+			alerts: [][]*model.AlertEvent{{fired(120), resolved(90)}},
+			want:   model.AlertStats{},
+		},
+		{
+			name:   "still firing",
+			alerts: [][]*model.AlertEvent{{fired(10)}},
+			want: model.AlertStats{
+				FiringCount:    1,
+				Transitions:    1,
+				TotalActiveSec: 10 * 60,
+				MeanActiveSec:  10 * 60,
+			},
+		},
+		{
+			name: "fired while silenced",
+			alerts: [][]*model.AlertEvent{{
+				{Event: model.AlertEventFired, ToState: model.AlertStateSuppressed, Timestamp: ago(30)},
+				suppressed(20),
+				resolved(10),
+			}},
+			want: model.AlertStats{
+				FiringCount:          1,
+				Transitions:          3,
+				TotalActiveSec:       20 * 60,
+				MeanActiveSec:        20 * 60,
+				MeanTimeToResolveSec: 20 * 60,
+			},
+		},
+		{
+			name: "fired after it started",
+			alerts: [][]*model.AlertEvent{{
+				{Event: model.AlertEventFired, ToState: model.AlertStateActive, Timestamp: ago(20), StartsAt: ago(25)},
+				resolved(5),
+			}},
+			want: model.AlertStats{
+				FiringCount:          1,
+				Transitions:          2,
+				TotalActiveSec:       20 * 60,
+				MeanActiveSec:        20 * 60,
+				MeanTimeToResolveSec: 20 * 60,
+			},
+		},
+		{
+			name: "many episodes of many alerts",
+			alerts: [][]*model.AlertEvent{
+				{fired(50), resolved(40), fired(30), resolved(10)},
+				{fired(40), suppressed(35), resolved(30)},
+			},
+			want: model.AlertStats{
+				FiringCount:              3,
+				Transitions:              7,
+				TotalActiveSec:           40 * 60,
+				MeanActiveSec:            40 * 60 / 3,
+				MeanTimeToResolveSec:     40 * 60 / 3,
+				MeanTimeToAcknowledgeSec: 5 * 60,
+			},
+		},
+	} {
+		acc := &statsAccumulator{since: ago(60), now: now}
+		for _, events := range test.alerts {
+			acc.add(events)
+		}
+		got := acc.toStats(time.Hour)
+		test.want.Window = "1h"
+		if !reflect.DeepEqual(got, &test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *got, test.want)
+		}
+	}
+}
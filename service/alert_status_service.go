@@ -0,0 +1,74 @@
+package service
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// statusLock serializes the updates of the alert statuses, which several
+// synchronizers record parts of.
+var statusLock sync.Mutex
+
+// GetAlertStatus returns the status recorded for the alert, an empty one if
+// none was recorded yet.
+func GetAlertStatus(alert *model.Alert) *model.AlertStatus {
+	status := &model.AlertStatus{
+		AlertID:     alert.Id,
+		Environment: alert.Environment,
+	}
+	status.Id = alert.Id
+
+	//the statuses are read for every alert listed, so they are all cached
+	obj, ok, err := cache.find(model.AlertStatusKind, alert.Id)
+	if err != nil || !ok {
+		return status
+	}
+	json.Unmarshal(obj.Data, status)
+
+	return status
+}
+
+// UpdateAlertStatus applies update to the status of the alert and records it
+// if update reports a change.
+func UpdateAlertStatus(alert *model.Alert, update func(status *model.AlertStatus) bool) error {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	_, exists, err := cache.find(model.AlertStatusKind, alert.Id)
+	if err != nil {
+		return err
+	}
+
+	status := GetAlertStatus(alert)
+	if !update(status) {
+		return nil
+	}
+	b, err := json.Marshal(*status)
+	if err != nil {
+		return err
+	}
+
+	obj := &Object{
+		Kind: model.AlertStatusKind,
+		ID:   status.Id,
+		Data: b,
+	}
+	if !exists {
+		return createObject(obj)
+	}
+	return updateObject(obj)
+}
+
+// DeleteAlertStatus forgets the status of a deleted alert.
+func DeleteAlertStatus(id string) error {
+	statusLock.Lock()
+	defer statusLock.Unlock()
+
+	_, exists, err := cache.find(model.AlertStatusKind, id)
+	if err != nil || !exists {
+		return err
+	}
+	return deleteObject(model.AlertStatusKind, id)
+}
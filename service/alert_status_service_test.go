@@ -0,0 +1,94 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+func TestListingAlertStatusesHitsStoreOnce(t *testing.T) {
+	s := useMemStore(t)
+
+	for i := 0; i < 20; i++ {
+		alert := &model.Alert{Environment: "1a5"}
+		if err := CreateAlert(alert); err != nil {
+			t.Fatal(err)
+		}
+		//half of the alerts have no status recorded
+		if i%2 == 0 {
+			continue
+		}
+		err := UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+			status.StartsAt = time.Now()
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	lists, gets := s.calls()
+
+	for i := 0; i < 3; i++ {
+		alerts, err := ListAlert("1a5")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 20 {
+			t.Fatalf("listed %d alerts, want 20", len(alerts))
+		}
+		recorded := 0
+		for _, alert := range alerts {
+			if !GetAlertStatus(alert).StartsAt.IsZero() {
+				recorded++
+			}
+		}
+		if recorded != 10 {
+			t.Errorf("got %d recorded statuses, want 10", recorded)
+		}
+	}
+
+	if l, g := s.calls(); l-lists > 1 || g != gets {
+		t.Errorf("listing the alerts with their statuses made %d lists and %d gets, want at most 1 list", l-lists, g-gets)
+	}
+}
+
+func TestUpdateAlertStatus(t *testing.T) {
+	useMemStore(t)
+	alert := &model.Alert{Environment: "1a5"}
+	if err := CreateAlert(alert); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+			status.SelectorEmpty = !status.SelectorEmpty
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if GetAlertStatus(alert).SelectorEmpty {
+		t.Error("status not updated in place")
+	}
+
+	//an update reporting no change is not written
+	err := UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+		status.SelectorEmpty = true
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if GetAlertStatus(alert).SelectorEmpty {
+		t.Error("unchanged status written")
+	}
+
+	if err := DeleteAlertStatus(alert.Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteAlertStatus(alert.Id); err != nil {
+		t.Errorf("deleting a missing status failed: %v", err)
+	}
+}
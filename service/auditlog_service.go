@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/sluu99/uuid"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// ListAuditLog returns the audit logs matching the given filters, newest first.
+// Empty filters and zero times match everything.
+func ListAuditLog(environment string, resourceID string, since time.Time, until time.Time) ([]*model.AuditLog, error) {
+	objs, err := cache.list(model.AuditLogKind, environment)
+	if err != nil {
+		logrus.Errorf("fail to list audit log,err:%v", err)
+		return nil, err
+	}
+
+	var logs []*model.AuditLog
+	for _, obj := range objs {
+		l := &model.AuditLog{}
+		json.Unmarshal(obj.Data, l)
+		if resourceID != "" && l.ResourceID != resourceID {
+			continue
+		}
+		if !since.IsZero() && l.Timestamp.Before(since) {
+			continue
+		}
+		if !until.IsZero() && l.Timestamp.After(until) {
+			continue
+		}
+		logs = append(logs, l)
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Timestamp.After(logs[j].Timestamp) })
+
+	return logs, nil
+}
+
+func GetAuditLog(id string) (*model.AuditLog, error) {
+	obj, err := cache.get(model.AuditLogKind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	log := &model.AuditLog{}
+	err = json.Unmarshal(obj.Data, log)
+	if err != nil {
+		return nil, err
+	}
+
+	return log, nil
+}
+
+func CreateAuditLog(log *model.AuditLog) error {
+	log.Id = uuid.Rand().Hex()
+	if log.Timestamp.IsZero() {
+		log.Timestamp = time.Now()
+	}
+
+	b, err := json.Marshal(*log)
+	if err != nil {
+		return err
+	}
+
+	return createObject(&Object{
+		Kind: model.AuditLogKind,
+		ID:   log.Id,
+		Data: b,
+	})
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// objectCache keeps the objects of every kind listed so far in memory,
+// indexed by kind and environment, so that listing does not hit the store.
+// Writes through the service layer update it in place and it is reloaded
+// periodically to pick up changes made behind our back.
+type objectCache struct {
+	store Store
+
+	mu sync.RWMutex
+	// byID is kind -> id -> object
+	byID map[string]map[string]*Object
+	// byEnv is kind -> environment -> id -> object
+	byEnv map[string]map[string]map[string]*Object
+	// generation is bumped on every write of a kind, so that a reload that
+	// raced with a write is not applied
+	generation map[string]int64
+}
+
+// maxLoadRetries is how many times a listing racing with writes is retried
+const maxLoadRetries = 3
+
+var cache *objectCache
+
+func newObjectCache(s Store) *objectCache {
+	return &objectCache{
+		store:      s,
+		byID:       map[string]map[string]*Object{},
+		byEnv:      map[string]map[string]map[string]*Object{},
+		generation: map[string]int64{},
+	}
+}
+
+// list returns the objects of kind in environment, or of all environments
+// when environment is empty.
+func (c *objectCache) list(kind string, environment string) ([]*Object, error) {
+	c.mu.RLock()
+	objs, loaded := c.lookup(kind, environment)
+	c.mu.RUnlock()
+	if loaded {
+		return objs, nil
+	}
+
+	if err := c.load(kind); err != nil {
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	objs, _ = c.lookup(kind, environment)
+	return objs, nil
+}
+
+// get returns the object from the cache, falling back to the store when it
+// is not cached yet.
+func (c *objectCache) get(kind string, id string) (*Object, error) {
+	c.mu.RLock()
+	obj, ok := c.byID[kind][id]
+	c.mu.RUnlock()
+	if ok {
+		return obj, nil
+	}
+
+	return c.store.Get(kind, id)
+}
+
+// find returns the object from the cache, loading every object of kind first
+// when the kind is not cached yet, and whether it exists. Unlike get it does
+// not fall back to the store for the objects missing from the cache, for
+// kinds looked up for objects that often do not exist.
+func (c *objectCache) find(kind string, id string) (*Object, bool, error) {
+	c.mu.RLock()
+	_, loaded := c.byID[kind]
+	c.mu.RUnlock()
+	if !loaded {
+		if err := c.load(kind); err != nil {
+			return nil, false, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	obj, ok := c.byID[kind][id]
+	return obj, ok, nil
+}
+
+func (c *objectCache) lookup(kind string, environment string) ([]*Object, bool) {
+	all, loaded := c.byID[kind]
+	if !loaded {
+		return nil, false
+	}
+
+	objMap := all
+	if environment != "" {
+		objMap = c.byEnv[kind][environment]
+	}
+
+	objs := make([]*Object, 0, len(objMap))
+	for _, obj := range objMap {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].ID < objs[j].ID })
+
+	return objs, true
+}
+
+// load reads every object of kind from the store and replaces the cached ones.
+func (c *objectCache) load(kind string) error {
+	for i := 0; ; i++ {
+		c.mu.RLock()
+		generation := c.generation[kind]
+		c.mu.RUnlock()
+
+		objs, err := c.store.List(kind)
+		if err != nil {
+			return err
+		}
+
+		byID := map[string]*Object{}
+		byEnv := map[string]map[string]*Object{}
+		for _, obj := range objs {
+			byID[obj.ID] = obj
+			env := environmentOf(obj)
+			if byEnv[env] == nil {
+				byEnv[env] = map[string]*Object{}
+			}
+			byEnv[env][obj.ID] = obj
+		}
+
+		c.mu.Lock()
+		_, loaded := c.byID[kind]
+		//a write raced with the listing, the snapshot may miss it
+		if c.generation[kind] != generation && i < maxLoadRetries {
+			c.mu.Unlock()
+			continue
+		}
+		if c.generation[kind] != generation && loaded {
+			c.mu.Unlock()
+			return nil
+		}
+		c.byID[kind] = byID
+		c.byEnv[kind] = byEnv
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+// put adds or replaces an object after it has been written to the store.
+func (c *objectCache) put(obj *Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation[obj.Kind]++
+	if _, loaded := c.byID[obj.Kind]; !loaded {
+		return
+	}
+
+	c.removeLocked(obj.Kind, obj.ID)
+	c.byID[obj.Kind][obj.ID] = obj
+	env := environmentOf(obj)
+	if c.byEnv[obj.Kind][env] == nil {
+		c.byEnv[obj.Kind][env] = map[string]*Object{}
+	}
+	c.byEnv[obj.Kind][env][obj.ID] = obj
+}
+
+// remove drops an object after it has been deleted from the store.
+func (c *objectCache) remove(kind string, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation[kind]++
+	c.removeLocked(kind, id)
+}
+
+// invalidate drops every cached object of kind, used when the outcome of a
+// write is unknown.
+func (c *objectCache) invalidate(kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation[kind]++
+	delete(c.byID, kind)
+	delete(c.byEnv, kind)
+}
+
+func (c *objectCache) removeLocked(kind string, id string) {
+	obj, ok := c.byID[kind][id]
+	if !ok {
+		return
+	}
+	delete(c.byID[kind], id)
+	delete(c.byEnv[kind][environmentOf(obj)], id)
+}
+
+func (c *objectCache) refresh() {
+	c.mu.RLock()
+	kinds := []string{}
+	for kind := range c.byID {
+		kinds = append(kinds, kind)
+	}
+	c.mu.RUnlock()
+
+	for _, kind := range kinds {
+		if err := c.load(kind); err != nil {
+			logrus.Errorf("Error while refreshing %s cache: %v", kind, err)
+		}
+	}
+}
+
+// RunCacheRefresher reloads the cached objects from the store every interval
+// until stopc is closed.
+func RunCacheRefresher(interval time.Duration, stopc <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			cache.refresh()
+		case <-stopc:
+			return nil
+		}
+	}
+}
+
+func environmentOf(obj *Object) string {
+	meta := struct {
+		Environment string `json:"environment"`
+	}{}
+	json.Unmarshal(obj.Data, &meta)
+	return meta.Environment
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func testObject(kind, id, environment string, version int64) *Object {
+	data := fmt.Sprintf(`{"environment":%q,"resourceVersion":%d}`, environment, version)
+	return &Object{Kind: kind, ID: id, Data: []byte(data)}
+}
+
+// cachedIDs returns the ids of the cached objects of kind in environment.
+func cachedIDs(t *testing.T, kind, environment string) []string {
+	objs, err := cache.list(kind, environment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, obj := range objs {
+		ids = append(ids, obj.ID)
+	}
+	return ids
+}
+
+func TestCacheList(t *testing.T) {
+	s := useMemStore(t)
+	for _, obj := range []*Object{
+		testObject("alert", "b", "1a5", 1),
+		testObject("alert", "a", "1a5", 1),
+		testObject("alert", "c", "1a6", 1),
+	} {
+		s.Update(obj)
+	}
+
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "")); ids != "[a b c]" {
+		t.Errorf("listed %s, want [a b c]", ids)
+	}
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "1a5")); ids != "[a b]" {
+		t.Errorf("listed %s in 1a5, want [a b]", ids)
+	}
+	if lists, _ := s.calls(); lists != 1 {
+		t.Errorf("listing made %d lists, want 1", lists)
+	}
+
+	//a moved object leaves its old environment
+	if err := createObject(testObject("alert", "a", "1a6", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "1a6")); ids != "[a c]" {
+		t.Errorf("listed %s in 1a6, want [a c]", ids)
+	}
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "1a5")); ids != "[b]" {
+		t.Errorf("listed %s in 1a5, want [b]", ids)
+	}
+}
+
+func TestCacheUnloadedKind(t *testing.T) {
+	s := useMemStore(t)
+
+	//a put does not mark the kind loaded, the other objects are not cached
+	s.Update(testObject("recipient", "a", "1a5", 1))
+	if err := createObject(testObject("recipient", "b", "1a5", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := cache.get("recipient", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.ID != "a" {
+		t.Errorf("got %s, want a", obj.ID)
+	}
+	if _, gets := s.calls(); gets != 1 {
+		t.Errorf("get of an unloaded kind made %d gets, want 1", gets)
+	}
+	if _, err := cache.get("recipient", "missing"); err == nil {
+		t.Error("get of a missing object succeeded")
+	}
+
+	if ids := fmt.Sprint(cachedIDs(t, "recipient", "")); ids != "[a b]" {
+		t.Errorf("listed %s, want [a b]", ids)
+	}
+
+	//once loaded, gets are served from the cache
+	_, gets := s.calls()
+	if _, err := cache.get("recipient", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, g := s.calls(); g != gets {
+		t.Errorf("get of a loaded kind made %d gets, want 0", g-gets)
+	}
+}
+
+func TestCacheLoadRetriesRacingWrite(t *testing.T) {
+	s := useMemStore(t)
+	s.Update(testObject("alert", "a", "1a5", 1))
+
+	//the first listing misses an object written while it runs
+	raced := false
+	s.onList = func(kind string) {
+		if !raced {
+			raced = true
+			if err := createObject(testObject(kind, "b", "1a5", 1)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "")); ids != "[a b]" {
+		t.Errorf("listed %s, want [a b]", ids)
+	}
+	if lists, _ := s.calls(); lists != 2 {
+		t.Errorf("load made %d lists, want 2", lists)
+	}
+}
+
+func TestCacheStaleReloadKeepsPut(t *testing.T) {
+	s := useMemStore(t)
+	s.Update(testObject("alert", "a", "1a5", 1))
+	cachedIDs(t, "alert", "")
+
+	//every reload races with a write, its snapshots are always stale
+	n := 0
+	s.onList = func(kind string) {
+		n++
+		if err := createObject(testObject(kind, fmt.Sprintf("new%d", n), "1a5", 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lists, _ := s.calls()
+	cache.refresh()
+	s.onList = nil
+
+	if l, _ := s.calls(); l-lists != maxLoadRetries+1 {
+		t.Errorf("reload made %d lists, want %d", l-lists, maxLoadRetries+1)
+	}
+	want := "[a new1 new2 new3 new4]"
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "")); ids != want {
+		t.Errorf("listed %s, want %s", ids, want)
+	}
+}
+
+func TestCacheFailedWriteInvalidates(t *testing.T) {
+	s := useMemStore(t)
+	s.Update(testObject("alert", "a", "1a5", 1))
+	cachedIDs(t, "alert", "")
+
+	s.failing = true
+	if err := updateObject(testObject("alert", "a", "1a6", 2)); err == nil {
+		t.Fatal("update of a failing store succeeded")
+	}
+	s.failing = false
+
+	lists, _ := s.calls()
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "1a5")); ids != "[a]" {
+		t.Errorf("listed %s in 1a5, want [a]", ids)
+	}
+	if l, _ := s.calls(); l != lists+1 {
+		t.Errorf("listing after a failed write made %d lists, want 1", l-lists)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	s := useMemStore(t)
+	s.Update(testObject("alert", "a", "1a5", 1))
+	cachedIDs(t, "alert", "")
+
+	if err := compareAndSwap(testObject("alert", "a", "1a5", 2), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	//an update based on the old version conflicts and leaves the store alone
+	if err := compareAndSwap(testObject("alert", "a", "1a6", 2), 1); err != ErrConflict {
+		t.Fatalf("update of a stale version returned %v, want ErrConflict", err)
+	}
+	obj, err := cache.get("alert", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := environmentOf(obj); env != "1a5" {
+		t.Errorf("cached object is in %s, want 1a5", env)
+	}
+
+	//a conflict refreshes the cached object written behind our back
+	s.Update(testObject("alert", "a", "1a7", 5))
+	if err := compareAndSwap(testObject("alert", "a", "1a5", 3), 2); err != ErrConflict {
+		t.Fatalf("update of a stale version returned %v, want ErrConflict", err)
+	}
+	if ids := fmt.Sprint(cachedIDs(t, "alert", "1a7")); ids != "[a]" {
+		t.Errorf("listed %s in 1a7, want [a]", ids)
+	}
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	s := useMemStore(t)
+	s.Update(testObject("alert", "a", "1a5", 1))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- compareAndSwap(testObject("alert", "a", "1a5", 2), 1)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			succeeded++
+		case ErrConflict:
+		default:
+			t.Errorf("concurrent update failed: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent updates of the same version succeeded, want 1", succeeded)
+	}
+}
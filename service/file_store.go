@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// fileStore keeps every object as a JSON file under <dir>/<kind>/<id>.json.
+type fileStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("missing store directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) List(kind string) ([]*Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, kind))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*Object
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		id := strings.TrimSuffix(f.Name(), ".json")
+		data, err := ioutil.ReadFile(s.path(kind, id))
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, &Object{Kind: kind, ID: id, Data: data})
+	}
+
+	return objs, nil
+}
+
+func (s *fileStore) Get(kind string, id string) (*Object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := ioutil.ReadFile(s.path(kind, id))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("can not find the %s for id %s", kind, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{Kind: kind, ID: id, Data: data}, nil
+}
+
+func (s *fileStore) Create(obj *Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.path(obj.Kind, obj.ID)); err == nil {
+		return fmt.Errorf("the %s %s already exists", obj.Kind, obj.ID)
+	}
+
+	return s.write(obj)
+}
+
+func (s *fileStore) Update(obj *Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.path(obj.Kind, obj.ID)); os.IsNotExist(err) {
+		return fmt.Errorf("can not find the %s for id %s", obj.Kind, obj.ID)
+	}
+
+	return s.write(obj)
+}
+
+func (s *fileStore) Delete(kind string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(kind, id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("can not find the %s for id %s", kind, id)
+	}
+
+	return err
+}
+
+func (s *fileStore) path(kind string, id string) string {
+	return filepath.Join(s.dir, kind, filepath.Base(id)+".json")
+}
+
+// write replaces the object file atomically so that a crash never leaves a
+// half written document behind.
+func (s *fileStore) write(obj *Object) error {
+	dir := filepath.Join(s.dir, obj.Kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(obj.Data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(obj.Kind, obj.ID))
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	v2client "github.com/rancher/go-rancher/v2"
+	"github.com/zionwu/monitoring-manager/config"
+)
+
+// genericObjectStore keeps objects as Cattle GenericObjects, with the JSON
+// document in resourceData.data.
+type genericObjectStore struct {
+}
+
+func (s *genericObjectStore) List(kind string) ([]*Object, error) {
+	geObjList, err := paginateGenericObjects(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	var objs []*Object
+	for _, gobj := range geObjList {
+		objs = append(objs, toObject(gobj))
+	}
+
+	return objs, nil
+}
+
+func (s *genericObjectStore) Get(kind string, id string) (*Object, error) {
+	gobj, err := getGenericObjectById(kind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toObject(gobj), nil
+}
+
+func (s *genericObjectStore) Create(obj *Object) error {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = rclient.GenericObject.Create(toGenericObject(obj))
+	return err
+}
+
+func (s *genericObjectStore) Update(obj *Object) error {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return err
+	}
+
+	existing, err := getGenericObjectById(obj.Kind, obj.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = rclient.GenericObject.Update(&existing, toGenericObject(obj))
+	return err
+}
+
+func (s *genericObjectStore) Delete(kind string, id string) error {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return err
+	}
+
+	existing, err := getGenericObjectById(kind, id)
+	if err != nil {
+		return err
+	}
+
+	return rclient.GenericObject.Delete(&existing)
+}
+
+func toObject(gobj v2client.GenericObject) *Object {
+	data, _ := gobj.ResourceData["data"].(string)
+	return &Object{
+		Kind: gobj.Kind,
+		ID:   gobj.Key,
+		Data: []byte(data),
+	}
+}
+
+func toGenericObject(obj *Object) *v2client.GenericObject {
+	return &v2client.GenericObject{
+		Name: obj.ID,
+		Key:  obj.ID,
+		Kind: obj.Kind,
+		ResourceData: map[string]interface{}{
+			"data": string(obj.Data),
+		},
+	}
+}
+
+func paginateGenericObjects(kind string) ([]v2client.GenericObject, error) {
+	result := []v2client.GenericObject{}
+	limit := "1000"
+	marker := ""
+	var pageData []v2client.GenericObject
+	var err error
+	for {
+		logrus.Debugf("paging got:%v,%v,%v", kind, limit, marker)
+		pageData, marker, err = getGenericObjects(kind, limit, marker)
+		if err != nil {
+			logrus.Debugf("get genericobject err:%v", err)
+			return nil, err
+		}
+		result = append(result, pageData...)
+		if marker == "" {
+			break
+		}
+	}
+	return result, nil
+}
+
+func getGenericObjectById(kind string, id string) (v2client.GenericObject, error) {
+
+	rclient, err := getRancherClient()
+	if err != nil {
+		return v2client.GenericObject{}, err
+	}
+
+	filters := make(map[string]interface{})
+	filters["key"] = id
+	filters["kind"] = kind
+	goCollection, err := rclient.GenericObject.List(&v2client.ListOpts{
+		Filters: filters,
+	})
+
+	if err != nil {
+		logrus.Errorf("Error %v filtering genericObjects by key", err)
+		return v2client.GenericObject{}, err
+	}
+
+	if len(goCollection.Data) == 0 {
+		return v2client.GenericObject{}, fmt.Errorf("can not find the %s for id %s", kind, id)
+	}
+
+	return goCollection.Data[0], nil
+}
+
+func getGenericObjects(kind string, limit string, marker string) ([]v2client.GenericObject, string, error) {
+
+	rclient, err := getRancherClient()
+	if err != nil {
+		return nil, "", err
+	}
+
+	filters := make(map[string]interface{})
+	filters["kind"] = kind
+	filters["limit"] = limit
+	filters["marker"] = marker
+	goCollection, err := rclient.GenericObject.List(&v2client.ListOpts{
+		Filters: filters,
+	})
+	if err != nil {
+		logrus.Errorf("fail querying generic objects, error:%v", err)
+		return nil, "", err
+	}
+	//get next marker
+	nextMarker := ""
+	if goCollection.Pagination != nil && goCollection.Pagination.Next != "" {
+		r, err := url.Parse(goCollection.Pagination.Next)
+		if err != nil {
+			logrus.Errorf("fail parsing next url, error:%v", err)
+			return nil, "", err
+		}
+		nextMarker = r.Query().Get("marker")
+	}
+	return goCollection.Data, nextMarker, err
+
+}
+
+func getRancherClient() (*v2client.RancherClient, error) {
+	c := config.GetConfig()
+	url := fmt.Sprintf("%s/v2-beta/schemas", c.CattleURL)
+	rclient, err := v2client.NewRancherClient(&v2client.ClientOpts{
+		Timeout:   time.Second * 30,
+		Url:       url,
+		AccessKey: c.CattleAccessKey,
+		SecretKey: c.CattleSecretKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rclient, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// historyPruneInterval is how often the expired audit logs and alert events
+// are deleted.
+const historyPruneInterval = time.Hour
+
+// historyKinds are the kinds recording the history of the environments,
+// which only grow unless pruned.
+var historyKinds = []string{model.AuditLogKind, model.AlertEventKind}
+
+// RunHistoryPruner deletes the audit logs and alert events older than
+// retention every hour until stopc is closed.
+func RunHistoryPruner(retention time.Duration, stopc <-chan struct{}) error {
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		pruneHistory(time.Now().Add(-retention))
+
+		select {
+		case <-ticker.C:
+		case <-stopc:
+			return nil
+		}
+	}
+}
+
+// pruneHistory deletes the audit logs and alert events recorded before
+// cutoff.
+func pruneHistory(cutoff time.Time) {
+	for _, kind := range historyKinds {
+		objs, err := cache.list(kind, "")
+		if err != nil {
+			logrus.Errorf("Error while listing %s to prune: %v", kind, err)
+			continue
+		}
+
+		pruned := 0
+		for _, obj := range objs {
+			meta := struct {
+				Timestamp time.Time `json:"timestamp"`
+			}{}
+			if err := json.Unmarshal(obj.Data, &meta); err != nil || !meta.Timestamp.Before(cutoff) {
+				continue
+			}
+			if err := deleteObject(kind, obj.ID); err != nil {
+				logrus.Errorf("Error while pruning %s %s: %v", kind, obj.ID, err)
+				continue
+			}
+			pruned++
+		}
+		if pruned > 0 {
+			logrus.Infof("Pruned %d %s recorded before %s", pruned, kind, cutoff.Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/sluu99/uuid"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+func ListMaintenanceWindow(environment string) ([]*model.MaintenanceWindow, error) {
+	objs, err := cache.list(model.MaintenanceWindowKind, environment)
+	if err != nil {
+		logrus.Errorf("fail to list maintenance window,err:%v", err)
+		return nil, err
+	}
+
+	var windows []*model.MaintenanceWindow
+	for _, obj := range objs {
+		a := &model.MaintenanceWindow{}
+		json.Unmarshal(obj.Data, a)
+		windows = append(windows, a)
+	}
+
+	return windows, nil
+}
+
+func DeleteMaintenanceWindow(id string) error {
+	return deleteObject(model.MaintenanceWindowKind, id)
+}
+
+func GetMaintenanceWindow(id string) (*model.MaintenanceWindow, error) {
+	obj, err := cache.get(model.MaintenanceWindowKind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	window := &model.MaintenanceWindow{}
+	err = json.Unmarshal(obj.Data, window)
+	if err != nil {
+		return nil, err
+	}
+
+	return window, nil
+}
+
+func CreateMaintenanceWindow(window *model.MaintenanceWindow) error {
+	window.Id = uuid.Rand().Hex()
+	window.ResourceVersion = 1
+
+	b, err := json.Marshal(*window)
+	if err != nil {
+		return err
+	}
+
+	return createObject(&Object{
+		Kind: model.MaintenanceWindowKind,
+		ID:   window.Id,
+		Data: b,
+	})
+}
+
+// UpdateMaintenanceWindow saves window if it is still at its resource version, otherwise
+// ErrConflict is returned. On success the version is bumped in place.
+func UpdateMaintenanceWindow(window *model.MaintenanceWindow) error {
+	version := window.ResourceVersion
+	window.ResourceVersion++
+	b, err := json.Marshal(*window)
+	if err != nil {
+		window.ResourceVersion = version
+		return err
+	}
+
+	err = compareAndSwap(&Object{
+		Kind: model.MaintenanceWindowKind,
+		ID:   window.Id,
+		Data: b,
+	}, version)
+	if err != nil {
+		window.ResourceVersion = version
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/sluu99/uuid"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+func ListNotificationTemplate(environment string) ([]*model.NotificationTemplate, error) {
+	objs, err := cache.list(model.NotificationTemplateKind, environment)
+	if err != nil {
+		logrus.Errorf("fail to list notification template,err:%v", err)
+		return nil, err
+	}
+
+	var templates []*model.NotificationTemplate
+	for _, obj := range objs {
+		a := &model.NotificationTemplate{}
+		json.Unmarshal(obj.Data, a)
+		templates = append(templates, a)
+	}
+
+	return templates, nil
+}
+
+func DeleteNotificationTemplate(id string) error {
+	return deleteObject(model.NotificationTemplateKind, id)
+}
+
+func GetNotificationTemplate(id string) (*model.NotificationTemplate, error) {
+	obj, err := cache.get(model.NotificationTemplateKind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &model.NotificationTemplate{}
+	err = json.Unmarshal(obj.Data, template)
+	if err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+func CreateNotificationTemplate(template *model.NotificationTemplate) error {
+	template.Id = uuid.Rand().Hex()
+	template.ResourceVersion = 1
+
+	b, err := json.Marshal(*template)
+	if err != nil {
+		return err
+	}
+
+	return createObject(&Object{
+		Kind: model.NotificationTemplateKind,
+		ID:   template.Id,
+		Data: b,
+	})
+}
+
+// UpdateNotificationTemplate saves template if it is still at its resource version, otherwise
+// ErrConflict is returned. On success the version is bumped in place.
+func UpdateNotificationTemplate(template *model.NotificationTemplate) error {
+	version := template.ResourceVersion
+	template.ResourceVersion++
+	b, err := json.Marshal(*template)
+	if err != nil {
+		template.ResourceVersion = version
+		return err
+	}
+
+	err = compareAndSwap(&Object{
+		Kind: model.NotificationTemplateKind,
+		ID:   template.Id,
+		Data: b,
+	}, version)
+	if err != nil {
+		template.ResourceVersion = version
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+
+	v2client "github.com/rancher/go-rancher/v2"
+)
+
+// GetContainerUUID returns the uuid of a Rancher container, which cAdvisor
+// exposes as the io.rancher.container.uuid container label.
+func GetContainerUUID(id string) (string, error) {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return "", err
+	}
+
+	container, err := rclient.Container.ById(id)
+	if err != nil {
+		return "", err
+	}
+	if container == nil {
+		return "", fmt.Errorf("can not find the container for id %s", id)
+	}
+
+	return container.Uuid, nil
+}
+
+// GetStackServiceName returns the stack/service name of a Rancher service,
+// which cAdvisor exposes as the io.rancher.stack_service.name container label.
+func GetStackServiceName(id string) (string, error) {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return "", err
+	}
+
+	svc, err := rclient.Service.ById(id)
+	if err != nil {
+		return "", err
+	}
+	if svc == nil {
+		return "", fmt.Errorf("can not find the service for id %s", id)
+	}
+
+	stack, err := rclient.Stack.ById(svc.StackId)
+	if err != nil {
+		return "", err
+	}
+	if stack == nil {
+		return "", fmt.Errorf("can not find the stack for id %s", svc.StackId)
+	}
+
+	return stack.Name + "/" + svc.Name, nil
+}
+
+// ListServiceIDs returns the ids of the services of the environment that are
+// in the stack, when given, and carry all the labels.
+func ListServiceIDs(environment, stackID string, labels map[string]string) ([]string, error) {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := map[string]interface{}{}
+	filters["accountId"] = environment
+	filters["limit"] = -1
+	if stackID != "" {
+		filters["stackId"] = stackID
+	}
+
+	services, err := rclient.Service.List(&v2client.ListOpts{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, svc := range services.Data {
+		var svcLabels map[string]interface{}
+		if svc.LaunchConfig != nil {
+			svcLabels = svc.LaunchConfig.Labels
+		}
+		if hasLabels(svcLabels, labels) {
+			ids = append(ids, svc.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+// ListHostIDs returns the ids of the hosts of the environment that carry all
+// the labels.
+func ListHostIDs(environment string, labels map[string]string) ([]string, error) {
+	rclient, err := getRancherClient()
+	if err != nil {
+		return nil, err
+	}
+
+	filters := map[string]interface{}{}
+	filters["accountId"] = environment
+	filters["limit"] = -1
+
+	hosts, err := rclient.Host.List(&v2client.ListOpts{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, host := range hosts.Data {
+		if hasLabels(host.Labels, labels) {
+			ids = append(ids, host.Id)
+		}
+	}
+
+	return ids, nil
+}
+
+func hasLabels(actual map[string]interface{}, expected map[string]string) bool {
+	for k, v := range expected {
+		a, ok := actual[k]
+		if !ok || fmt.Sprint(a) != v {
+			return false
+		}
+	}
+	return true
+}
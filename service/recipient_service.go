@@ -6,57 +6,37 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/sluu99/uuid"
 	"github.com/zionwu/monitoring-manager/model"
-
-	v2client "github.com/rancher/go-rancher/v2"
 )
 
 func ListRecipient(environment string) ([]*model.Recipient, error) {
-	geObjList, err := paginateGenericObjects("recipient")
+	objs, err := cache.list(model.RecipientKind, environment)
 	if err != nil {
-		logrus.Errorf("fail to list alertConfig,err:%v", err)
+		logrus.Errorf("fail to list recipient,err:%v", err)
 		return nil, err
 	}
 
 	var recipients []*model.Recipient
-	for _, gobj := range geObjList {
-		b := []byte(gobj.ResourceData["data"].(string))
+	for _, obj := range objs {
 		a := &model.Recipient{}
-		json.Unmarshal(b, a)
-		if environment == "" || a.Environment == environment {
-			recipients = append(recipients, a)
-		}
+		json.Unmarshal(obj.Data, a)
+		recipients = append(recipients, a)
 	}
 
 	return recipients, nil
 }
 
 func DeleteRecipient(id string) error {
-
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
-	data, err := getGenericObjectById("recipient", id)
-	if err != nil {
-		return err
-	}
-
-	if err = rclient.GenericObject.Delete(&data); err != nil {
-		return err
-	}
-
-	return nil
+	return deleteObject(model.RecipientKind, id)
 }
 
 func GetRecipient(id string) (*model.Recipient, error) {
-	data, err := getGenericObjectById("recipient", id)
+	obj, err := cache.get(model.RecipientKind, id)
 	if err != nil {
 		return nil, err
 	}
 
 	recipient := &model.Recipient{}
-	err = json.Unmarshal([]byte(data.ResourceData["data"].(string)), recipient)
+	err = json.Unmarshal(obj.Data, recipient)
 	if err != nil {
 		return nil, err
 	}
@@ -65,60 +45,41 @@ func GetRecipient(id string) (*model.Recipient, error) {
 }
 
 func CreateRecipient(recipient *model.Recipient) error {
-
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
 	recipient.Id = uuid.Rand().Hex()
+	recipient.ResourceVersion = 1
 
 	b, err := json.Marshal(*recipient)
 	if err != nil {
 		return err
 	}
-	resourceData := map[string]interface{}{
-		"data": string(b),
-	}
 
-	_, err = rclient.GenericObject.Create(&v2client.GenericObject{
-		Name:         recipient.Id,
-		Key:          recipient.Id,
-		ResourceData: resourceData,
-		Kind:         "recipient",
+	return createObject(&Object{
+		Kind: model.RecipientKind,
+		ID:   recipient.Id,
+		Data: b,
 	})
-	if err != nil {
-		return err
-	}
-
-	return nil
 }
 
+// UpdateRecipient saves recipient if it is still at its resource version, otherwise
+// ErrConflict is returned. On success the version is bumped in place.
 func UpdateRecipient(recipient *model.Recipient) error {
-
-	rclient, err := getRancherClient()
-	if err != nil {
-		return err
-	}
-
-	recipientGO, err := getGenericObjectById("recipient", recipient.Id)
+	version := recipient.ResourceVersion
+	recipient.ResourceVersion++
+	b, err := json.Marshal(*recipient)
 	if err != nil {
+		recipient.ResourceVersion = version
 		return err
 	}
 
-	b, err := json.Marshal(*recipient)
+	err = compareAndSwap(&Object{
+		Kind: model.RecipientKind,
+		ID:   recipient.Id,
+		Data: b,
+	}, version)
 	if err != nil {
+		recipient.ResourceVersion = version
 		return err
 	}
-	resourceData := map[string]interface{}{
-		"data": string(b),
-	}
 
-	_, err = rclient.GenericObject.Update(&recipientGO, &v2client.GenericObject{
-		Name:         recipient.Id,
-		Key:          recipient.Id,
-		ResourceData: resourceData,
-		Kind:         "recipient",
-	})
 	return nil
 }
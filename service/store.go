@@ -0,0 +1,114 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/zionwu/monitoring-manager/config"
+)
+
+const (
+	StoreCattle = "cattle"
+	StoreFile   = "file"
+)
+
+// Object is a single resource as it is persisted by a Store: an opaque JSON
+// document identified by its kind and id.
+type Object struct {
+	Kind string
+	ID   string
+	Data []byte
+}
+
+// Store is the storage backend for alerts, recipients and the alert config.
+type Store interface {
+	List(kind string) ([]*Object, error)
+	Get(kind string, id string) (*Object, error)
+	Create(obj *Object) error
+	Update(obj *Object) error
+	Delete(kind string, id string) error
+}
+
+// ErrConflict is returned when an update is based on a stale resource version.
+var ErrConflict = errors.New("the object has been modified, please apply your changes to the latest version and try again")
+
+var (
+	store Store
+	// casLock serializes the read-compare-write of versioned updates.
+	casLock sync.Mutex
+)
+
+// InitStore sets up the storage backend selected in the config.
+func InitStore(cfg config.Config) error {
+	switch cfg.Store {
+	case StoreCattle:
+		store = &genericObjectStore{}
+	case StoreFile:
+		s, err := newFileStore(cfg.StoreDir)
+		if err != nil {
+			return err
+		}
+		store = s
+	default:
+		return fmt.Errorf("unknown store backend %s, should be %s/%s", cfg.Store, StoreCattle, StoreFile)
+	}
+	cache = newObjectCache(store)
+
+	return nil
+}
+
+func createObject(obj *Object) error {
+	if err := store.Create(obj); err != nil {
+		cache.invalidate(obj.Kind)
+		return err
+	}
+	cache.put(obj)
+
+	return nil
+}
+
+func updateObject(obj *Object) error {
+	if err := store.Update(obj); err != nil {
+		cache.invalidate(obj.Kind)
+		return err
+	}
+	cache.put(obj)
+
+	return nil
+}
+
+func deleteObject(kind string, id string) error {
+	if err := store.Delete(kind, id); err != nil {
+		cache.invalidate(kind)
+		return err
+	}
+	cache.remove(kind, id)
+
+	return nil
+}
+
+// compareAndSwap writes obj only if the stored object is still at version.
+func compareAndSwap(obj *Object, version int64) error {
+	casLock.Lock()
+	defer casLock.Unlock()
+
+	current, err := store.Get(obj.Kind, obj.ID)
+	if err != nil {
+		return err
+	}
+
+	meta := struct {
+		ResourceVersion int64 `json:"resourceVersion"`
+	}{}
+	if err := json.Unmarshal(current.Data, &meta); err != nil {
+		return err
+	}
+	if meta.ResourceVersion != version {
+		cache.put(current)
+		return ErrConflict
+	}
+
+	return updateObject(obj)
+}
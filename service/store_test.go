@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// memStore is an in memory Store counting the calls made to it.
+type memStore struct {
+	mu      sync.Mutex
+	objs    map[string]map[string]*Object
+	lists   int
+	gets    int
+	onList  func(kind string)
+	failing bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{objs: map[string]map[string]*Object{}}
+}
+
+func (s *memStore) List(kind string) ([]*Object, error) {
+	s.mu.Lock()
+	s.lists++
+	objs := []*Object{}
+	for _, obj := range s.objs[kind] {
+		objs = append(objs, obj)
+	}
+	onList := s.onList
+	s.mu.Unlock()
+
+	//runs outside of the lock, so that it may write to the store
+	if onList != nil {
+		onList(kind)
+	}
+	return objs, nil
+}
+
+func (s *memStore) Get(kind string, id string) (*Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gets++
+	obj, ok := s.objs[kind][id]
+	if !ok {
+		return nil, fmt.Errorf("%s %s not found", kind, id)
+	}
+	return obj, nil
+}
+
+func (s *memStore) Create(obj *Object) error {
+	return s.Update(obj)
+}
+
+func (s *memStore) Update(obj *Object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing {
+		return fmt.Errorf("store unavailable")
+	}
+	if s.objs[obj.Kind] == nil {
+		s.objs[obj.Kind] = map[string]*Object{}
+	}
+	s.objs[obj.Kind][obj.ID] = obj
+	return nil
+}
+
+func (s *memStore) Delete(kind string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objs[kind], id)
+	return nil
+}
+
+func (s *memStore) calls() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lists, s.gets
+}
+
+// useMemStore makes the service layer use a new memStore for the test.
+func useMemStore(t *testing.T) *memStore {
+	s := newMemStore()
+	oldStore, oldCache := store, cache
+	store, cache = s, newObjectCache(s)
+	t.Cleanup(func() {
+		store, cache = oldStore, oldCache
+	})
+	return s
+}
@@ -0,0 +1,342 @@
+package sync
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/prometheus/alertmanager/types"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/zionwu/monitoring-manager/model"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+// maintenanceSynchronizer silences the alerts covered by maintenance windows
+// while they are in progress, and marks those alerts as in maintenance.
+type maintenanceSynchronizer struct {
+}
+
+func (s *maintenanceSynchronizer) Run(stopc <-chan struct{}) error {
+
+	tickChan := time.NewTicker(time.Second * 30).C
+
+	for {
+		select {
+		case <-tickChan:
+			if err := s.sync(time.Now()); err != nil {
+				logrus.Errorf("Error occurred while syncing maintenance windows: %v", err)
+			}
+
+		case <-stopc:
+			return nil
+		}
+	}
+
+}
+
+func (s *maintenanceSynchronizer) sync(now time.Time) error {
+
+	windows, err := service.ListMaintenanceWindow("")
+	if err != nil {
+		logrus.Errorf("Error while listing maintenance window: %v", err)
+		return err
+	}
+
+	//silences lost by Alertmanager, e.g. on a restart, are created again
+	var activeSilences map[string]bool
+	if silences, err := util.ListSilences(); err != nil {
+		logrus.Errorf("Error while listing silences: %v", err)
+	} else {
+		activeSilences = map[string]bool{}
+		for _, silence := range silences {
+			if silence.Status.State == types.SilenceStateActive {
+				activeSilences[silence.ID] = true
+			}
+		}
+	}
+
+	//targets of the windows in progress, nil for a whole environment
+	active := []*model.MaintenanceWindow{}
+	targets := map[string]map[string]bool{}
+
+	for _, window := range windows {
+		from, to, err := currentOccurrence(window, now)
+		if err != nil {
+			logrus.Errorf("Error while scheduling maintenance window %s: %v", window.Id, err)
+			continue
+		}
+
+		if from.IsZero() {
+			if window.Active {
+				s.end(window)
+			}
+			continue
+		}
+
+		ids := expandTargets(window)
+		lost := activeSilences != nil && window.Active && !allActive(window.SilenceIDs, activeSilences)
+		if !window.Active || !window.ActiveFrom.Equal(from) || lost {
+			if err := s.start(window, ids, from, to, activeSilences); err != nil {
+				logrus.Errorf("Error while starting maintenance window %s: %v", window.Id, err)
+				continue
+			}
+		}
+
+		active = append(active, window)
+		if window.TargetType != "" {
+			targets[window.Id] = map[string]bool{}
+			for _, id := range ids {
+				targets[window.Id][id] = true
+			}
+		}
+	}
+
+	alerts, err := service.ListAlert("")
+	if err != nil {
+		logrus.Errorf("Error while listing alert: %v", err)
+		return err
+	}
+
+	for _, alert := range alerts {
+		windowID := ""
+		//the targets covered by the alert are only resolved when needed
+		var covered []string
+		resolved := false
+		for _, window := range active {
+			if window.Environment != alert.Environment {
+				continue
+			}
+			if window.TargetType == "" {
+				windowID = window.Id
+				break
+			}
+			if !resolved {
+				covered, resolved = coveredTargets(alert), true
+			}
+			if anyTarget(covered, targets[window.Id]) {
+				windowID = window.Id
+				break
+			}
+		}
+
+		//recorded on the status, leaving the version of the alert alone
+		err := service.UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+			if status.MaintenanceWindowID == windowID {
+				return false
+			}
+			status.MaintenanceWindowID = windowID
+			return true
+		})
+		if err != nil {
+			logrus.Errorf("Error while marking maintenance of alert %s: %v", alert.Id, err)
+		}
+	}
+
+	return nil
+}
+
+// start silences the targets of the window until the occurrence ends, and
+// expires the silences it replaces that are still active.
+func (s *maintenanceSynchronizer) start(window *model.MaintenanceWindow, ids []string, from, to time.Time, activeSilences map[string]bool) error {
+	env := &prommodel.Matcher{Name: "environment", Value: window.Environment}
+
+	matcherSets := [][]*prommodel.Matcher{}
+	if window.TargetType == "" {
+		matcherSets = append(matcherSets, []*prommodel.Matcher{env})
+	} else {
+		quoted := []string{}
+		for _, id := range ids {
+			quoted = append(quoted, regexp.QuoteMeta(id))
+		}
+		value := strings.Join(quoted, "|")
+
+		matcherSets = append(matcherSets, []*prommodel.Matcher{env, {Name: "target_id", Value: value, IsRegex: true}})
+		//resource, container and metric alerts only know the host of a series
+		if window.TargetType == model.TargetTypeHost {
+			matcherSets = append(matcherSets, []*prommodel.Matcher{env, {Name: "host_id", Value: value, IsRegex: true}})
+		}
+		//container alerts know the service of a series by its name, the
+		//services of a stack window follow its stacks
+		var serviceIDs []string
+		switch window.TargetType {
+		case model.TargetTypeService:
+			serviceIDs = ids
+		case model.TargetTypeStack:
+			serviceIDs = ids[len(window.TargetIDs):]
+		}
+		if names := serviceNames(serviceIDs); len(names) > 0 {
+			matcherSets = append(matcherSets, []*prommodel.Matcher{env, {Name: "container_label_io_rancher_stack_service_name", Value: strings.Join(names, "|"), IsRegex: true}})
+		}
+	}
+
+	silenceIDs := []string{}
+	for _, matchers := range matcherSets {
+		silence := &model.AlertSilenceSpec{
+			Comment:   fmt.Sprintf("maintenance window %s: %s", window.Id, window.Description),
+			CreatedBy: "maintenanceWindow",
+			StartsAt:  time.Now(),
+			EndsAt:    to,
+		}
+		if err := util.CreateSilence(matchers, silence); err != nil {
+			expireSilences(silenceIDs)
+			return err
+		}
+		silenceIDs = append(silenceIDs, silence.SilenceID)
+	}
+
+	replaced := []string{}
+	for _, id := range window.SilenceIDs {
+		if activeSilences[id] {
+			replaced = append(replaced, id)
+		}
+	}
+
+	window.Active = true
+	window.ActiveFrom = from
+	window.ActiveTo = to
+	window.SilenceIDs = silenceIDs
+	if err := service.UpdateMaintenanceWindow(window); err != nil {
+		expireSilences(silenceIDs)
+		return err
+	}
+	expireSilences(replaced)
+
+	return nil
+}
+
+// allActive reports whether all the silences are active.
+func allActive(ids []string, activeSilences map[string]bool) bool {
+	for _, id := range ids {
+		if !activeSilences[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// end marks the window as over, its silences expired by themselves.
+func (s *maintenanceSynchronizer) end(window *model.MaintenanceWindow) {
+	window.Active = false
+	window.ActiveFrom = time.Time{}
+	window.ActiveTo = time.Time{}
+	window.SilenceIDs = nil
+	if err := service.UpdateMaintenanceWindow(window); err != nil {
+		logrus.Errorf("Error while ending maintenance window %s: %v", window.Id, err)
+	}
+}
+
+func expireSilences(ids []string) {
+	for _, id := range ids {
+		if err := util.DeleteSilence(id); err != nil {
+			logrus.Errorf("Error while expiring silence %s: %v", id, err)
+		}
+	}
+}
+
+// currentOccurrence returns the start and end of the occurrence of the window
+// in progress at now, or zero times when there is none.
+func currentOccurrence(window *model.MaintenanceWindow, now time.Time) (time.Time, time.Time, error) {
+	d, err := prommodel.ParseDuration(window.Duration)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	duration := time.Duration(d)
+
+	from := window.StartsAt
+	if window.Schedule != "" {
+		schedule, err := util.ParseCron(window.Schedule)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		loc, err := time.LoadLocation(window.Timezone)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+
+		//the earliest start of an occurrence that has not ended yet
+		from = schedule.Next(now.In(loc).Add(-duration))
+	}
+
+	if from.IsZero() || from.After(now) || !now.Before(from.Add(duration)) {
+		return time.Time{}, time.Time{}, nil
+	}
+
+	return from, from.Add(duration), nil
+}
+
+// coveredTargets returns the ids of the hosts, stacks and services the alert
+// covers: its target, the targets its selector selects, the service of its
+// containers, and the hosts and targets of its firing instances, which is all
+// that is known of metric alerts.
+func coveredTargets(alert *model.Alert) []string {
+	ids := []string{}
+	if alert.TargetID != "" {
+		ids = append(ids, alert.TargetID)
+	}
+	if !alert.TargetSelector.IsEmpty() {
+		selected, err := selectedTargets(alert)
+		if err != nil {
+			logrus.Errorf("Error while selecting targets of alert %s: %v", alert.Id, err)
+		}
+		ids = append(ids, selected...)
+	}
+	if alert.TargetType == model.TargetTypeContainer && alert.ContainerRule.ServiceID != "" {
+		ids = append(ids, alert.ContainerRule.ServiceID)
+	}
+	for _, instance := range service.GetAlertStatus(alert).Instances {
+		for _, label := range []string{"host_id", "target_id"} {
+			if id := instance.Labels[label]; id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+func anyTarget(ids []string, targets map[string]bool) bool {
+	for _, id := range ids {
+		if targets[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceNames returns the stack/service names cAdvisor labels the containers
+// of the services with, quoted for a regular expression.
+func serviceNames(ids []string) []string {
+	names := []string{}
+	for _, id := range ids {
+		name, err := service.GetStackServiceName(id)
+		if err != nil {
+			logrus.Errorf("Error while getting name of service %s: %v", id, err)
+			continue
+		}
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	return names
+}
+
+// expandTargets returns the targets of the window, along with the services
+// of the stacks of a stack window.
+func expandTargets(window *model.MaintenanceWindow) []string {
+	ids := append([]string{}, window.TargetIDs...)
+	if window.TargetType != model.TargetTypeStack {
+		return ids
+	}
+
+	for _, stackID := range window.TargetIDs {
+		serviceIDs, err := service.ListServiceIDs(window.Environment, stackID, nil)
+		if err != nil {
+			logrus.Errorf("Error while listing services of stack %s: %v", stackID, err)
+			continue
+		}
+		ids = append(ids, serviceIDs...)
+	}
+
+	return ids
+}
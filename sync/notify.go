@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/sluu99/uuid"
+	"golang.org/x/net/context"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/zionwu/monitoring-manager/model"
+	alertconfig "github.com/zionwu/monitoring-manager/model/alertmanager"
+	"github.com/zionwu/monitoring-manager/service"
+	"github.com/zionwu/monitoring-manager/util"
+)
+
+// testNotifyTimeout bounds the delivery of a test alert.
+const testNotifyTimeout = 30 * time.Second
+
+// SendTestAlert delivers a synthetic alert to the recipient through the
+// notifiers of Alertmanager, with the receiver generated for the recipient,
+// and returns the error of the delivery. Every test alert is unique, so none
+// is deduplicated with an earlier one.
+func SendTestAlert(recipient *model.Recipient) error {
+	notifier, err := service.GetAlertConfig()
+	if err != nil {
+		return err
+	}
+	templateList, err := service.ListNotificationTemplate("")
+	if err != nil {
+		return err
+	}
+
+	var recipientTmpl *model.NotificationTemplate
+	for _, t := range templateList {
+		if t.Id == recipient.TemplateID {
+			recipientTmpl = t
+		}
+	}
+	receiver, err := testReceiver(notifier, recipient, recipientTmpl)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := util.LoadNotificationTemplates(templateList)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	labels := prommodel.LabelSet{
+		"alertname":         "RancherTestAlert",
+		"test_recipient_id": prommodel.LabelValue(recipient.Id),
+		"test_id":           prommodel.LabelValue(uuid.Rand().Hex()),
+		"environment":       prommodel.LabelValue(recipient.Environment),
+		"severity":          "info",
+		"description":       "Test alert from Rancher",
+	}
+	alert := &types.Alert{
+		Alert: prommodel.Alert{
+			Labels:      labels,
+			Annotations: prommodel.LabelSet{},
+			StartsAt:    now,
+			EndsAt:      now.Add(5 * time.Minute),
+		},
+		UpdatedAt: now,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testNotifyTimeout)
+	defer cancel()
+	ctx = notify.WithReceiverName(ctx, recipient.Id)
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("{}:{test_recipient_id=%q}", recipient.Id))
+	ctx = notify.WithGroupLabels(ctx, labels)
+	ctx = notify.WithNow(ctx, now)
+
+	integrations := notify.BuildReceiverIntegrations(receiver, tmpl, log.NewNopLogger())
+	if len(integrations) == 0 {
+		return fmt.Errorf("recipient type %s has no notifier", recipient.RecipientType)
+	}
+	for _, integration := range integrations {
+		if _, err := integration.Notify(ctx, alert); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// testReceiver returns the receiver generated for the recipient, with the
+// settings it takes from the global config filled in like Alertmanager does
+// when it loads the config.
+func testReceiver(notifier *model.AlertConfig, recipient *model.Recipient, tmpl *model.NotificationTemplate) (*amconfig.Receiver, error) {
+	s := &alertRouteSynchronizer{}
+	config := getDefaultConfig()
+	s.addReceiver2Config(config, notifier, recipient, tmpl)
+	s.addGlobal2Config(config, notifier)
+
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	config, err = alertconfig.Load(string(configBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var generated *alertconfig.Receiver
+	for _, r := range config.Receivers {
+		if r.Name == recipient.Id {
+			generated = r
+		}
+	}
+	if generated == nil {
+		return nil, fmt.Errorf("no receiver generated for recipient %s", recipient.Id)
+	}
+
+	//the settings of the vendored notifiers are those of the generated config,
+	//except the OpsGenie API host
+	plain := *generated
+	plain.OpsGenieConfigs = nil
+	b, err := yaml.Marshal(&plain)
+	if err != nil {
+		return nil, err
+	}
+	receiver := &amconfig.Receiver{}
+	if err := yaml.Unmarshal(b, receiver); err != nil {
+		return nil, err
+	}
+	for _, c := range generated.OpsGenieConfigs {
+		receiver.OpsGenieConfigs = append(receiver.OpsGenieConfigs, &amconfig.OpsGenieConfig{
+			NotifierConfig: amconfig.NotifierConfig{VSendResolved: c.VSendResolved},
+			APIKey:         amconfig.Secret(c.APIKey),
+			APIURL:         c.APIHost,
+			Message:        c.Message,
+			Description:    c.Description,
+			Source:         c.Source,
+			Details:        c.Details,
+			Teams:          c.Teams,
+			Tags:           c.Tags,
+			Note:           c.Note,
+		})
+	}
+
+	return receiver, nil
+}
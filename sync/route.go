@@ -2,6 +2,9 @@ package sync
 
 import (
 	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
 
 	"github.com/Sirupsen/logrus"
 	prommodel "github.com/prometheus/common/model"
@@ -16,6 +19,19 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
+// RenderAlertManagerConfig returns the Alertmanager config the route
+// synchronizer writes for the alerts and recipients, and the errors of the
+// recipients and environments Alertmanager would reject, by id, which are left
+// out of it. With an environment, the routes, receivers and inhibit rules
+// generated for the other environments are left out.
+func RenderAlertManagerConfig(environment string, alerts []*model.Alert, recipients []*model.Recipient) ([]byte, map[string]error, error) {
+	templateList, err := service.ListNotificationTemplate("")
+	if err != nil {
+		return nil, nil, err
+	}
+	return (&alertRouteSynchronizer{}).render(environment, alerts, recipients, templateList)
+}
+
 type alertRouteSynchronizer struct {
 	alertChan <-chan struct{}
 }
@@ -26,7 +42,7 @@ func (s *alertRouteSynchronizer) Run(stopc <-chan struct{}) error {
 		select {
 		case <-s.alertChan:
 			if err := s.sync(); err != nil {
-				logrus.Errorf("Error occurred while syncing prometheus rules:  %v", err)
+				logrus.Errorf("Error occurred while syncing alertmanager config: %v", err)
 			}
 
 		case <-stopc:
@@ -38,12 +54,6 @@ func (s *alertRouteSynchronizer) Run(stopc <-chan struct{}) error {
 
 func (s *alertRouteSynchronizer) sync() error {
 
-	notifier, err := service.GetAlertConfig()
-	if err != nil {
-		logrus.Errorf("Error while getting notifier: %v", err)
-		return err
-	}
-
 	alertList, err := service.ListAlert("")
 	if err != nil {
 		logrus.Errorf("Error while listing alert: %v", err)
@@ -56,53 +66,170 @@ func (s *alertRouteSynchronizer) sync() error {
 		return err
 	}
 
-	config := getDefaultConfig()
+	templateList, err := service.ListNotificationTemplate("")
+	if err != nil {
+		logrus.Errorf("Error while listing notification template: %v", err)
+		return err
+	}
 
-	if notifier.ResolveTimeout != "" {
-		rt, err := prommodel.ParseDuration(notifier.ResolveTimeout)
-		if err == nil {
-			config.Global.ResolveTimeout = rt
-		}
+	configBytes, _, err := s.render("", alertList, recipientList, templateList)
+	if err != nil {
+		return err
+	}
+
+	cfg := mconfig.GetConfig()
+
+	//the templates file sits next to the config, where Alertmanager resolves
+	//the relative path of it
+	templateFile := util.ConfigFile{
+		Path: filepath.Join(filepath.Dir(cfg.AlertManagerConfig), notificationTemplateFile),
+		Data: []byte(util.NotificationTemplateDefs(templateList)),
+	}
+	configFile := util.ConfigFile{
+		Path: cfg.AlertManagerConfig,
+		Data: configBytes,
 	}
 
-	if notifier.EmailConfig.SMTPAuthPassword != "" {
-		//config.Global.SMTPAuthIdentity = notifier.EmailConfig.SMTPAuthIdentity
-		config.Global.SMTPAuthPassword = alertconfig.Secret(notifier.EmailConfig.SMTPAuthPassword)
-		//config.Global.SMTPAuthSecret = alertconfig.Secret(notifier.EmailConfig.SMTPAuthSecret)
-		config.Global.SMTPAuthUsername = notifier.EmailConfig.SMTPAuthUserName
-		config.Global.SMTPFrom = notifier.EmailConfig.SMTPAuthUserName
-		config.Global.SMTPSmarthost = notifier.EmailConfig.SMTPSmartHost
-		config.Global.SMTPRequireTLS = false
+	//reload alertmanager
+	err = util.ApplyConfiguration(cfg.AlertManagerURL, templateFile, configFile)
+	if err != nil {
+		logrus.Errorf("Error while applying the config: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// render returns the config of the alerts and recipients merged into the
+// current one, checked the way Alertmanager loads it, and the errors of the
+// recipients and environments left out of it, by id. Alertmanager rejects a
+// config as a whole, so the recipients and then the environments it would
+// reject are left out to keep the others working. With an environment, only
+// the generated parts of that environment are kept.
+func (s *alertRouteSynchronizer) render(environment string, alertList []*model.Alert, recipientList []*model.Recipient, templateList []*model.NotificationTemplate) ([]byte, map[string]error, error) {
+
+	notifier, err := service.GetAlertConfig()
+	if err != nil {
+		logrus.Errorf("Error while getting notifier: %v", err)
+		return nil, nil, err
+	}
+
+	templates := map[string]*model.NotificationTemplate{}
+	for _, t := range templateList {
+		templates[t.Id] = t
+	}
+
+	current, err := loadAlertManagerConfig(mconfig.GetConfig().AlertManagerConfig)
+	if err != nil {
+		logrus.Errorf("Error while loading the alertmanager config: %v", err)
+		return nil, nil, err
 	}
 
+	rejected := map[string]error{}
+	recipients := []*model.Recipient{}
 	for _, recipient := range recipientList {
-		s.addReceiver2Config(config, recipient)
+		if err := s.checkConfig(notifier, templates, nil, []*model.Recipient{recipient}); err != nil {
+			logrus.Errorf("Error while validating the receiver of recipient %s: %v", recipient.Id, err)
+			rejected[recipient.Id] = err
+			continue
+		}
+		recipients = append(recipients, recipient)
 	}
 
+	environments := []string{}
+	envAlerts := map[string][]*model.Alert{}
+	envRecipients := map[string][]*model.Recipient{}
 	for _, alert := range alertList {
-		if alert.State == model.AlertStateDisabled {
+		if _, ok := envAlerts[alert.Environment]; !ok {
+			environments = append(environments, alert.Environment)
+		}
+		envAlerts[alert.Environment] = append(envAlerts[alert.Environment], alert)
+	}
+	for _, recipient := range recipients {
+		envRecipients[recipient.Environment] = append(envRecipients[recipient.Environment], recipient)
+	}
+
+	alerts := []*model.Alert{}
+	for _, env := range environments {
+		if err := s.checkConfig(notifier, templates, envAlerts[env], envRecipients[env]); err != nil {
+			logrus.Errorf("Error while validating the config of environment %s: %v", env, err)
+			rejected[env] = err
 			continue
 		}
-		s.addRoute2Config(config, alert)
+		alerts = append(alerts, envAlerts[env]...)
+	}
+	kept := []*model.Recipient{}
+	for _, recipient := range recipients {
+		if rejected[recipient.Environment] == nil {
+			kept = append(kept, recipient)
+		}
 	}
 
+	config := s.generateConfig(notifier, templates, alerts, kept)
+
+	//the global settings set in the alert config override the current ones
+	config = mergeConfig(current, config)
+	s.addGlobal2Config(config, notifier)
+
 	configBytes, err := yaml.Marshal(config)
 	logrus.Debugf("after updating: %s", string(configBytes))
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	cfg := mconfig.GetConfig()
-	err = ioutil.WriteFile(cfg.AlertManagerConfig, configBytes, 0777)
-	if err != nil {
-		logrus.Errorf("Error while writing the config to file: %s", err)
-		return err
+	//the parts added by hand may still make Alertmanager reject it
+	if _, err = alertconfig.Load(string(configBytes)); err != nil {
+		logrus.Errorf("Error while validating the config: %v", err)
+		return nil, nil, err
 	}
 
-	//reload alertmanager
-	go util.ReloadConfiguration(cfg.AlertManagerURL)
+	if environment == "" {
+		return configBytes, rejected, nil
+	}
+	filterConfig(config, environment, alertList, recipientList)
+	configBytes, err = yaml.Marshal(config)
+	return configBytes, rejected, err
+}
 
-	return nil
+// generateConfig returns the routes, receivers and inhibit rules generated for
+// the alerts and recipients, without the global settings.
+func (s *alertRouteSynchronizer) generateConfig(notifier *model.AlertConfig, templates map[string]*model.NotificationTemplate, alertList []*model.Alert, recipientList []*model.Recipient) *alertconfig.Config {
+	config := getDefaultConfig()
+
+	for _, recipient := range recipientList {
+		s.addReceiver2Config(config, notifier, recipient, templates[recipient.TemplateID])
+		if recipient.Id == notifier.DefaultRecipientID {
+			config.Route.Receiver = recipient.Id
+		}
+	}
+
+	for _, alert := range alertList {
+		if alert.State == model.AlertStateDisabled {
+			continue
+		}
+		s.addRoute2Config(config, alert)
+		s.addInhibitRule2Config(config, alert)
+	}
+
+	if len(templates) != 0 {
+		config.Templates = []string{notificationTemplateFile}
+	}
+
+	return config
+}
+
+// checkConfig loads the config generated for the alerts and recipients alone
+// the way Alertmanager does.
+func (s *alertRouteSynchronizer) checkConfig(notifier *model.AlertConfig, templates map[string]*model.NotificationTemplate, alertList []*model.Alert, recipientList []*model.Recipient) error {
+	config := s.generateConfig(notifier, templates, alertList, recipientList)
+	s.addGlobal2Config(config, notifier)
+
+	configBytes, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = alertconfig.Load(string(configBytes))
+	return err
 }
 
 func (s *alertRouteSynchronizer) addRoute2Config(config *alertconfig.Config, alert *model.Alert) error {
@@ -130,46 +257,242 @@ func (s *alertRouteSynchronizer) addRoute2Config(config *alertconfig.Config, ale
 		*envRoutes = append(*envRoutes, envRoute)
 	}
 
-	match := map[string]string{}
-	match["alert_id"] = alert.Id
-	route := &alertconfig.Route{
-		Receiver: alert.RecipientID,
-		Match:    match,
+	gw, gwErr := prommodel.ParseDuration(alert.AdvancedOptions.InitialWait)
+	ri, riErr := prommodel.ParseDuration(alert.AdvancedOptions.RepeatInterval)
+
+	//one sibling route per recipient, all but the last one continue matching.
+	//The recipients left out of the config are skipped.
+	recipientIDs := []string{}
+	for _, recipientID := range alert.GetRecipientIDs() {
+		if hasReceiver(config, recipientID) {
+			recipientIDs = append(recipientIDs, recipientID)
+		}
 	}
+	for i, recipientID := range recipientIDs {
+		match := map[string]string{}
+		match["alert_id"] = alert.Id
+		route := &alertconfig.Route{
+			Receiver: recipientID,
+			Match:    match,
+			Continue: i < len(recipientIDs)-1,
+		}
+
+		if gwErr == nil {
+			route.GroupWait = &gw
+		}
+		if riErr == nil {
+			route.RepeatInterval = &ri
+		}
 
-	gw, err := prommodel.ParseDuration(alert.AdvancedOptions.InitialWait)
-	if err == nil {
-		route.GroupWait = &gw
+		envRoute.Routes = append(envRoute.Routes, route)
 	}
-	ri, err := prommodel.ParseDuration(alert.AdvancedOptions.RepeatInterval)
-	if err == nil {
-		route.RepeatInterval = &ri
+
+	return nil
+}
+
+func hasReceiver(config *alertconfig.Config, name string) bool {
+	for _, receiver := range config.Receivers {
+		if receiver.Name == name {
+			return true
+		}
 	}
+	return false
+}
 
-	envRoute.Routes = append(envRoute.Routes, route)
+// addInhibitRule2Config inhibits the alert while the alerts it depends on
+// fire in the same environment.
+func (s *alertRouteSynchronizer) addInhibitRule2Config(config *alertconfig.Config, alert *model.Alert) {
+	equal := prommodel.LabelNames{"environment"}
+	for _, label := range alert.DependencyLabels {
+		equal = append(equal, prommodel.LabelName(label))
+	}
 
-	return nil
+	for _, dependency := range alert.DependsOn {
+		config.InhibitRules = append(config.InhibitRules, &alertconfig.InhibitRule{
+			SourceMatch: map[string]string{"alert_id": dependency},
+			TargetMatch: map[string]string{"alert_id": alert.Id},
+			Equal:       equal,
+		})
+	}
 }
 
-func (s *alertRouteSynchronizer) addReceiver2Config(config *alertconfig.Config, recipient *model.Recipient) error {
+func (s *alertRouteSynchronizer) addGlobal2Config(config *alertconfig.Config, notifier *model.AlertConfig) {
+
+	if notifier.ResolveTimeout != "" {
+		rt, err := prommodel.ParseDuration(notifier.ResolveTimeout)
+		if err == nil {
+			config.Global.ResolveTimeout = rt
+		}
+	}
+
+	email := notifier.EmailConfig
+	if email.SMTPSmartHost != "" {
+		config.Global.SMTPSmarthost = email.SMTPSmartHost
+		config.Global.SMTPAuthUsername = email.SMTPAuthUserName
+		config.Global.SMTPAuthPassword = alertconfig.Secret(email.SMTPAuthPassword)
+		config.Global.SMTPAuthIdentity = email.SMTPAuthIdentity
+		config.Global.SMTPAuthSecret = alertconfig.Secret(email.SMTPAuthSecret)
+		config.Global.SMTPRequireTLS = email.SMTPRequireTLS
+		config.Global.SMTPFrom = email.SMTPFrom
+		if config.Global.SMTPFrom == "" {
+			config.Global.SMTPFrom = email.SMTPAuthUserName
+		}
+	}
+
+	//unset settings are left alone, keeping those of the current config or
+	//the defaults of Alertmanager
+	if notifier.SlackConfig.APIURL != "" {
+		config.Global.SlackAPIURL = alertconfig.Secret(notifier.SlackConfig.APIURL)
+	}
+	if notifier.PagerDutyConfig.URL != "" {
+		config.Global.PagerdutyURL = notifier.PagerDutyConfig.URL
+	}
+	if notifier.OpsGenieConfig.APIHost != "" {
+		config.Global.OpsGenieAPIHost = notifier.OpsGenieConfig.APIHost
+	}
+	if notifier.VictorOpsConfig.APIURL != "" {
+		config.Global.VictorOpsAPIURL = notifier.VictorOpsConfig.APIURL
+	}
+	if notifier.VictorOpsConfig.APIKey != "" {
+		config.Global.VictorOpsAPIKey = alertconfig.Secret(notifier.VictorOpsConfig.APIKey)
+	}
+	if notifier.HipChatConfig.URL != "" {
+		config.Global.HipchatURL = notifier.HipChatConfig.URL
+	}
+	if notifier.HipChatConfig.AuthToken != "" {
+		config.Global.HipchatAuthToken = alertconfig.Secret(notifier.HipChatConfig.AuthToken)
+	}
+}
+
+// addReceiver2Config adds the receiver of the recipient, whose notifications
+// use the fields set in its notification template, if any.
+func (s *alertRouteSynchronizer) addReceiver2Config(config *alertconfig.Config, notifier *model.AlertConfig, recipient *model.Recipient, tmpl *model.NotificationTemplate) error {
+	if tmpl == nil {
+		tmpl = &model.NotificationTemplate{}
+	}
+	ref := func(field, text string) string {
+		if text == "" {
+			return ""
+		}
+		return util.NotificationTemplateRef(tmpl.Id, field)
+	}
+	//resolved alerts get the resolved variant of an email field when it is set
+	emailRef := func(field, text, resolvedField, resolvedText, defaultText string) string {
+		firing := defaultText
+		if text != "" {
+			firing = util.NotificationTemplateRef(tmpl.Id, field)
+		}
+		if resolvedText == "" {
+			return firing
+		}
+		return util.NotificationResolvedRef(firing, util.NotificationTemplateRef(tmpl.Id, resolvedField))
+	}
+	subject := emailRef(util.NotificationEmailSubject, tmpl.EmailSubject, util.NotificationEmailResolvedSubject, tmpl.EmailResolvedSubject, defaultEmailSubject)
+	emailText := emailRef(util.NotificationEmailText, tmpl.EmailText, util.NotificationEmailResolvedText, tmpl.EmailResolvedText, "")
+	emailHTML := emailRef(util.NotificationEmailHTML, tmpl.EmailHTML, util.NotificationEmailResolvedHTML, tmpl.EmailResolvedHTML, alertconfig.DefaultEmailConfig.HTML)
+	title := ref(util.NotificationTitle, tmpl.Title)
+	text := ref(util.NotificationText, tmpl.Text)
+
+	sendResolved := func(nc *alertconfig.NotifierConfig) {
+		if recipient.SendResolved != nil {
+			nc.VSendResolved = *recipient.SendResolved
+		}
+	}
 
 	receiver := &alertconfig.Receiver{Name: recipient.Id}
+	//the integrations start from the Alertmanager defaults, as some of their
+	//fields are always marshalled and would otherwise override them
 	switch recipient.RecipientType {
-	case "webhook":
-		webhook := &alertconfig.WebhookConfig{
-			URL: recipient.WebhookRecipient.URL,
+	case model.RecipientTypeWebhook:
+		webhook := alertconfig.DefaultWebhookConfig
+		webhook.URL = webhookURL(recipient.WebhookRecipient)
+		sendResolved(&webhook.NotifierConfig)
+		receiver.WebhookConfigs = append(receiver.WebhookConfigs, &webhook)
+
+	case model.RecipientTypeEmail:
+		email := alertconfig.DefaultEmailConfig
+		email.To = recipient.EmailRecipient.Address
+		email.Headers = map[string]string{"Subject": subject}
+		email.HTML = emailHTML
+		email.Text = emailText
+		sendResolved(&email.NotifierConfig)
+		receiver.EmailConfigs = append(receiver.EmailConfigs, &email)
+
+	case model.RecipientTypeSlack:
+		slack := alertconfig.DefaultSlackConfig
+		slack.Channel = recipient.SlackRecipient.Channel
+		slack.APIURL = alertconfig.Secret(recipient.SlackRecipient.APIURL)
+		if title != "" {
+			slack.Title = title
 		}
-		receiver.WebhookConfigs = append(receiver.WebhookConfigs, webhook)
-
-	case "email":
-		header := map[string]string{}
-		header["Subject"] = "Alert from Rancher: {{ (index .Alerts 0).Labels.description}}"
-		email := &alertconfig.EmailConfig{
-			To:      recipient.EmailRecipient.Address,
-			Headers: header,
-			//HTML:    "Resource Type:  {{ (index .Alerts 0).Labels.target_type}}\nResource Name:  {{ (index .Alerts 0).Labels.target_id}}\nNamespace:  {{ (index .Alerts 0).Labels.namespace}}\n",
+		if text != "" {
+			slack.Text = text
 		}
-		receiver.EmailConfigs = append(receiver.EmailConfigs, email)
+		sendResolved(&slack.NotifierConfig)
+		receiver.SlackConfigs = append(receiver.SlackConfigs, &slack)
+
+	case model.RecipientTypePagerDuty:
+		pagerduty := alertconfig.DefaultPagerdutyConfig
+		pagerduty.ServiceKey = alertconfig.Secret(recipient.PagerDutyRecipient.ServiceKey)
+		if title != "" {
+			pagerduty.Description = title
+		}
+		sendResolved(&pagerduty.NotifierConfig)
+		receiver.PagerdutyConfigs = append(receiver.PagerdutyConfigs, &pagerduty)
+
+	case model.RecipientTypeOpsGenie:
+		opsgenie := alertconfig.DefaultOpsGenieConfig
+		opsgenie.APIKey = alertconfig.Secret(recipient.OpsGenieRecipient.APIKey)
+		if opsgenie.APIKey == "" {
+			opsgenie.APIKey = alertconfig.Secret(notifier.OpsGenieConfig.APIKey)
+		}
+		opsgenie.Teams = recipient.OpsGenieRecipient.Teams
+		opsgenie.Tags = recipient.OpsGenieRecipient.Tags
+		if title != "" {
+			opsgenie.Message = title
+		}
+		if text != "" {
+			opsgenie.Description = text
+		}
+		sendResolved(&opsgenie.NotifierConfig)
+		receiver.OpsGenieConfigs = append(receiver.OpsGenieConfigs, &opsgenie)
+
+	case model.RecipientTypeVictorOps:
+		victorops := alertconfig.DefaultVictorOpsConfig
+		victorops.APIKey = alertconfig.Secret(recipient.VictorOpsRecipient.APIKey)
+		victorops.RoutingKey = recipient.VictorOpsRecipient.RoutingKey
+		if title != "" {
+			victorops.EntityDisplayName = title
+		}
+		if text != "" {
+			victorops.StateMessage = text
+		}
+		sendResolved(&victorops.NotifierConfig)
+		receiver.VictorOpsConfigs = append(receiver.VictorOpsConfigs, &victorops)
+
+	case model.RecipientTypeHipChat:
+		hipchat := alertconfig.DefaultHipchatConfig
+		hipchat.RoomID = recipient.HipChatRecipient.RoomID
+		hipchat.AuthToken = alertconfig.Secret(recipient.HipChatRecipient.AuthToken)
+		hipchat.Notify = recipient.HipChatRecipient.Notify
+		if text != "" {
+			hipchat.Message = text
+		}
+		sendResolved(&hipchat.NotifierConfig)
+		receiver.HipchatConfigs = append(receiver.HipchatConfigs, &hipchat)
+
+	case model.RecipientTypePushover:
+		pushover := alertconfig.DefaultPushoverConfig
+		pushover.UserKey = alertconfig.Secret(recipient.PushoverRecipient.UserKey)
+		pushover.Token = alertconfig.Secret(recipient.PushoverRecipient.Token)
+		if title != "" {
+			pushover.Title = title
+		}
+		if text != "" {
+			pushover.Message = text
+		}
+		sendResolved(&pushover.NotifierConfig)
+		receiver.PushoverConfigs = append(receiver.PushoverConfigs, &pushover)
 	}
 
 	config.Receivers = append(config.Receivers, receiver)
@@ -177,26 +500,206 @@ func (s *alertRouteSynchronizer) addReceiver2Config(config *alertconfig.Config,
 	return nil
 }
 
+// webhookURL returns the url of the webhook with the basic auth credentials
+// in it, which the HTTP client of Alertmanager sends.
+func webhookURL(spec model.WebhookRecipientSpec) string {
+	if spec.BasicAuthUsername == "" {
+		return spec.URL
+	}
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return spec.URL
+	}
+	u.User = url.UserPassword(spec.BasicAuthUsername, spec.BasicAuthPassword)
+	return u.String()
+}
+
+// loadAlertManagerConfig parses the Alertmanager config at path, nil when it
+// does not exist yet. Unlike alertconfig.LoadFile it keeps the config as it is
+// written: the receivers added by hand are not filled in with the global
+// settings, which the alert config may change later, nor rejected for lacking
+// them, and relative paths are not resolved against the directory seen here.
+func loadAlertManagerConfig(path string) (*alertconfig.Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &alertconfig.Config{}
+	type plain alertconfig.Config
+	if err := yaml.Unmarshal(content, (*plain)(config)); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// mergeConfig merges the generated config into the current one and returns
+// it. The manager owns the receiver of the root route, the routes below it
+// matching environment, the receivers those routes use along with the
+// default and recipient ones, the inhibit rules between alert ids and the
+// notification templates file, and replaces them. Anything else has been
+// added by hand and is kept as is, including the receivers of the routes
+// kept. The generated routes take the place of the first managed
+// route, so that they keep their position among the other routes.
+func mergeConfig(current, generated *alertconfig.Config) *alertconfig.Config {
+	if current == nil {
+		return generated
+	}
+
+	if current.Global == nil {
+		current.Global = generated.Global
+	}
+
+	managed := map[string]bool{}
+	kept := map[string]bool{}
+	if current.Route == nil {
+		current.Route = generated.Route
+	} else {
+		current.Route.Receiver = generated.Route.Receiver
+
+		routes := []*alertconfig.Route{}
+		merged := false
+		for _, route := range current.Route.Routes {
+			if !isManagedRoute(route) {
+				addRouteReceivers(route, kept)
+				routes = append(routes, route)
+				continue
+			}
+			addRouteReceivers(route, managed)
+			if !merged {
+				routes = append(routes, generated.Route.Routes...)
+				merged = true
+			}
+		}
+		if !merged {
+			routes = append(routes, generated.Route.Routes...)
+		}
+		current.Route.Routes = routes
+	}
+
+	replaced := map[string]bool{}
+	for _, receiver := range generated.Receivers {
+		replaced[receiver.Name] = true
+	}
+	receivers := []*alertconfig.Receiver{}
+	for _, receiver := range current.Receivers {
+		if replaced[receiver.Name] || (managed[receiver.Name] && !kept[receiver.Name]) {
+			continue
+		}
+		receivers = append(receivers, receiver)
+	}
+	current.Receivers = append(receivers, generated.Receivers...)
+
+	inhibitRules := []*alertconfig.InhibitRule{}
+	for _, rule := range current.InhibitRules {
+		if !isManagedInhibitRule(rule) {
+			inhibitRules = append(inhibitRules, rule)
+		}
+	}
+	current.InhibitRules = append(inhibitRules, generated.InhibitRules...)
+
+	templates := []string{}
+	for _, t := range current.Templates {
+		if t != notificationTemplateFile {
+			templates = append(templates, t)
+		}
+	}
+	current.Templates = append(templates, generated.Templates...)
+
+	return current
+}
+
+// filterConfig removes the routes, receivers and inhibit rules generated for
+// the alerts and recipients of other environments than environment. The root
+// receiver is kept even if it belongs to another one.
+func filterConfig(config *alertconfig.Config, environment string, alerts []*model.Alert, recipients []*model.Recipient) {
+	others := map[string]bool{}
+	for _, alert := range alerts {
+		if alert.Environment != environment {
+			others[alert.Id] = true
+		}
+	}
+	for _, recipient := range recipients {
+		if recipient.Environment != environment && recipient.Id != config.Route.Receiver {
+			others[recipient.Id] = true
+		}
+	}
+
+	routes := []*alertconfig.Route{}
+	for _, route := range config.Route.Routes {
+		if env, ok := route.Match["environment"]; ok && env != environment {
+			continue
+		}
+		routes = append(routes, route)
+	}
+	config.Route.Routes = routes
+
+	receivers := []*alertconfig.Receiver{}
+	for _, receiver := range config.Receivers {
+		if !others[receiver.Name] {
+			receivers = append(receivers, receiver)
+		}
+	}
+	config.Receivers = receivers
+
+	inhibitRules := []*alertconfig.InhibitRule{}
+	for _, rule := range config.InhibitRules {
+		if !isManagedInhibitRule(rule) || !others[rule.TargetMatch["alert_id"]] {
+			inhibitRules = append(inhibitRules, rule)
+		}
+	}
+	config.InhibitRules = inhibitRules
+}
+
+func isManagedRoute(route *alertconfig.Route) bool {
+	_, env := route.Match["environment"]
+	return env
+}
+
+func isManagedInhibitRule(rule *alertconfig.InhibitRule) bool {
+	_, source := rule.SourceMatch["alert_id"]
+	_, target := rule.TargetMatch["alert_id"]
+	return source && target
+}
+
+// addRouteReceivers adds the receivers used by the route and its children.
+func addRouteReceivers(route *alertconfig.Route, receivers map[string]bool) {
+	if route.Receiver != "" {
+		receivers[route.Receiver] = true
+	}
+	for _, r := range route.Routes {
+		addRouteReceivers(r, receivers)
+	}
+}
+
+// defaultReceiver is the receiver of alerts not routed to any recipient, it
+// has no integration so those alerts are dropped.
+const defaultReceiver = "default"
+
+// defaultEmailSubject is the subject of the emails of recipients without a
+// notification template setting one.
+const defaultEmailSubject = `{{ if eq .Status "resolved" }}Resolved alert{{ else }}Alert{{ end }} from Rancher: {{ (index .Alerts 0).Labels.description}}`
+
+// notificationTemplateFile is the name of the file defining the notification
+// templates, in the directory of the Alertmanager config.
+const notificationTemplateFile = "notification-templates.tmpl"
+
 func getDefaultConfig() *alertconfig.Config {
 	config := alertconfig.Config{}
 
 	resolveTimeout, _ := prommodel.ParseDuration("5m")
 	config.Global = &alertconfig.GlobalConfig{
-		SlackAPIURL:    "slack_api_url",
 		ResolveTimeout: resolveTimeout,
 		SMTPRequireTLS: false,
 	}
 
-	slackConfigs := []*alertconfig.SlackConfig{}
-	initSlackConfig := &alertconfig.SlackConfig{
-		Channel: "#alert",
-	}
-	slackConfigs = append(slackConfigs, initSlackConfig)
-
 	receivers := []*alertconfig.Receiver{}
 	initReceiver := &alertconfig.Receiver{
-		Name:         "rancherlabs",
-		SlackConfigs: slackConfigs,
+		Name: defaultReceiver,
 	}
 	receivers = append(receivers, initReceiver)
 
@@ -207,7 +710,7 @@ func getDefaultConfig() *alertconfig.Config {
 	repeatInterval, _ := prommodel.ParseDuration("1h")
 
 	config.Route = &alertconfig.Route{
-		Receiver:       "rancherlabs",
+		Receiver:       defaultReceiver,
 		GroupWait:      &groupWait,
 		GroupInterval:  &groupInterval,
 		RepeatInterval: &repeatInterval,
@@ -1,10 +1,18 @@
 package sync
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	prommodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/rulefmt"
 	"github.com/zionwu/monitoring-manager/config"
 	"github.com/zionwu/monitoring-manager/model"
 	"github.com/zionwu/monitoring-manager/service"
@@ -13,12 +21,26 @@ import (
 	yaml "gopkg.in/yaml.v2"
 )
 
+// RenderPrometheusRules returns the rules file the rule synchronizer writes
+// for the alerts.
+func RenderPrometheusRules(alerts []*model.Alert) ([]byte, error) {
+	return (&prometheusRuleSynchronizer{}).render(alerts)
+}
+
 type prometheusRuleSynchronizer struct {
 	promChan <-chan struct{}
+
+	//whether the target selector of each alert rendered by the last render
+	//selected no target
+	selectorEmpty map[string]bool
 }
 
 func (s *prometheusRuleSynchronizer) Run(stopc <-chan struct{}) error {
 
+	//target selectors are resolved on every sync, so resync periodically to
+	//pick up services and hosts that started or stopped matching
+	tickChan := time.NewTicker(time.Second * time.Duration(config.GetConfig().SyncIntervalSec)).C
+
 	for {
 		select {
 		case <-s.promChan:
@@ -26,6 +48,11 @@ func (s *prometheusRuleSynchronizer) Run(stopc <-chan struct{}) error {
 				logrus.Errorf("Error occurred while syncing prometheus rules:  %v", err)
 			}
 
+		case <-tickChan:
+			if err := s.sync(); err != nil {
+				logrus.Errorf("Error occurred while syncing prometheus rules:  %v", err)
+			}
+
 		case <-stopc:
 			return nil
 		}
@@ -42,74 +69,59 @@ func (s *prometheusRuleSynchronizer) sync() error {
 		return err
 	}
 
-	rules := []Rule{}
+	ruleStr, err := s.render(alertList)
+	if err != nil {
+		return err
+	}
+	s.recordSelectorStatus(alertList)
 
-	for _, alert := range alertList {
+	c := config.GetConfig()
+	if old, err := ioutil.ReadFile(c.PrometheusRule); err == nil && bytes.Equal(old, ruleStr) {
+		return nil
+	}
+
+	//reload prometheus configuration
+	err = util.ApplyConfiguration(c.PrometheusURL, util.ConfigFile{Path: c.PrometheusRule, Data: ruleStr})
+	if err != nil {
+		logrus.Errorf("Error while applying the rules: %v", err)
+		return err
+	}
+
+	return nil
+}
 
+// render returns the rules file of the alerts, leaving out the rules that
+// Prometheus would reject.
+func (s *prometheusRuleSynchronizer) render(alertList []*model.Alert) ([]byte, error) {
+	s.selectorEmpty = map[string]bool{}
+	rules := []Rule{}
+	for _, alert := range alertList {
 		if alert.State == model.AlertStateDisabled {
 			continue
 		}
 
-		labels := map[string]string{}
-		labels["alert_id"] = alert.Id
-		labels["severity"] = alert.Severity
-		labels["description"] = alert.Description
-		labels["target_type"] = alert.TargetType
-		labels["environment"] = alert.Environment
-
-		switch alert.TargetType {
-		case "metric":
-			holdDuration, _ := prommodel.ParseDuration(alert.MetricRule.HoldDuration)
-
-			rule := Rule{
-				Alert:  alert.Description,
-				Expr:   alert.MetricRule.Expr,
-				For:    holdDuration,
-				Labels: labels,
-			}
-			rules = append(rules, rule)
-		case "service":
-			holdDuration, _ := prommodel.ParseDuration(alert.ServiceRule.HoldDuration)
-			expr := "rancher_service_health_status{environment_id=\"" + alert.Environment + "\", id=\"" + alert.TargetID + "\", health_state=\"healthy\"} != 1"
-
-			rule := Rule{
-				Alert:  alert.Description,
-				Expr:   expr,
-				For:    holdDuration,
-				Labels: labels,
-			}
-			rules = append(rules, rule)
-
-		case "stack":
-			holdDuration, _ := prommodel.ParseDuration(alert.StackRule.HoldDuration)
-			expr := "rancher_stack_health_status{environment_id=\"" + alert.Environment + "\", id=\"" + alert.TargetID + "\", health_state=\"healthy\"} != 1"
-
-			rule := Rule{
-				Alert:  alert.Description,
-				Expr:   expr,
-				For:    holdDuration,
-				Labels: labels,
-			}
-			rules = append(rules, rule)
-
-		case "host":
-			holdDuration, _ := prommodel.ParseDuration(alert.HostRule.HoldDuration)
-			expr := "rancher_host_agent_state{environment_id=\"" + alert.Environment + "\", id=\"" + alert.TargetID + "\", state=\"active\"} != 1"
-
-			rule := Rule{
-				Alert:  alert.Description,
-				Expr:   expr,
-				For:    holdDuration,
-				Labels: labels,
-			}
-			rules = append(rules, rule)
+		rule, err := AlertRule(alert)
+		if err != nil {
+			logrus.Errorf("Error while generating rule for alert %s: %v", alert.Id, err)
+			continue
 		}
+		s.selectorEmpty[alert.Id] = rule.selectorEmpty
+		rules = append(rules, *rule)
+	}
 
+	//leave out the rules Prometheus would reject along with the whole file
+	validRules := []Rule{}
+	for _, rule := range rules {
+		if err := checkRule(rule); err != nil {
+			logrus.Errorf("Error while validating rule of alert %s: %v", rule.Labels["alert_id"], err)
+			continue
+		}
+		validRules = append(validRules, rule)
 	}
 
 	rg := RuleGroup{
 		Name:  "rancher-rules",
-		Rules: rules,
+		Rules: validRules,
 	}
 	ruleGroups := []RuleGroup{}
 	ruleGroups = append(ruleGroups, rg)
@@ -121,20 +133,358 @@ func (s *prometheusRuleSynchronizer) sync() error {
 	ruleStr, err := yaml.Marshal(rgs)
 	logrus.Debugf("after updating rules: %s", string(ruleStr))
 	if err != nil {
+		return nil, err
+	}
+
+	if err = checkRuleGroups(ruleStr); err != nil {
+		logrus.Errorf("Error while validating the rules: %v", err)
+		return nil, err
+	}
+
+	return ruleStr, nil
+}
+
+// recordSelectorStatus records on the status of the alerts whether their
+// target selector selected no target in the last render. The status of the
+// alerts whose rule could not be generated is kept.
+func (s *prometheusRuleSynchronizer) recordSelectorStatus(alertList []*model.Alert) {
+	for _, alert := range alertList {
+		empty, rendered := s.selectorEmpty[alert.Id]
+		if !rendered && alert.State != model.AlertStateDisabled {
+			continue
+		}
+
+		err := service.UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+			if status.SelectorEmpty == empty {
+				return false
+			}
+			status.SelectorEmpty = empty
+			return true
+		})
+		if err != nil {
+			logrus.Errorf("Error while recording the status of alert %s: %v", alert.Id, err)
+		}
+	}
+}
+
+// AlertRule compiles the alert into its alerting rule.
+func AlertRule(alert *model.Alert) (*Rule, error) {
+	labels := map[string]string{}
+	for k, v := range alert.Labels {
+		labels[k] = v
+	}
+	labels["alert_id"] = alert.Id
+	labels["severity"] = alert.Severity
+	labels["description"] = alert.Description
+	labels["target_type"] = alert.TargetType
+	labels["environment"] = alert.Environment
+	//alerts selecting many targets take the target_id of each series
+	if alert.TargetID != "" {
+		labels["target_id"] = alert.TargetID
+	}
+
+	annotations := map[string]string{}
+	for k, v := range alert.Annotations {
+		annotations[k] = v
+	}
+	if alert.Summary != "" {
+		annotations["summary"] = alert.Summary
+	}
+	if alert.RunbookURL != "" {
+		annotations["runbook_url"] = alert.RunbookURL
+	}
+
+	var expr, holdDuration string
+	selected := true
+	switch alert.TargetType {
+	case model.TargetTypeMetric:
+		expr = alert.MetricRule.Expr
+		holdDuration = alert.MetricRule.HoldDuration
+
+	case model.TargetTypeService:
+		matcher, ok, err := targetMatcher(alert, "id")
+		if err != nil {
+			return nil, err
+		}
+		selected = ok
+		expr = withTargetID(alert, "rancher_service_health_status{environment_id=\""+alert.Environment+"\", "+matcher+", health_state=\"healthy\"} != 1", "id")
+		holdDuration = alert.ServiceRule.HoldDuration
+
+	case model.TargetTypeStack:
+		expr = "rancher_stack_health_status{environment_id=\"" + alert.Environment + "\", id=\"" + alert.TargetID + "\", health_state=\"healthy\"} != 1"
+		holdDuration = alert.StackRule.HoldDuration
+
+	case model.TargetTypeHost:
+		matcher, ok, err := targetMatcher(alert, "id")
+		if err != nil {
+			return nil, err
+		}
+		selected = ok
+		expr = withTargetID(alert, "rancher_host_agent_state{environment_id=\""+alert.Environment+"\", "+matcher+", state=\"active\"} != 1", "id")
+		//name the host like the node_exporter and cAdvisor series, so
+		//that alerts can depend on the host per host_id
+		expr = "label_replace(" + expr + ", \"host_id\", \"$1\", \"id\", \"(.*)\")"
+		holdDuration = alert.HostRule.HoldDuration
+
+	case model.TargetTypeHostResource:
+		var err error
+		if expr, selected, err = hostResourceExpr(alert); err != nil {
+			return nil, err
+		}
+		holdDuration = alert.HostResourceRule.HoldDuration
+
+	case model.TargetTypeContainer:
+		var err error
+		if expr, err = containerExpr(alert); err != nil {
+			return nil, err
+		}
+		holdDuration = alert.ContainerRule.HoldDuration
+
+	default:
+		return nil, fmt.Errorf("unknown target type %s", alert.TargetType)
+	}
+
+	//durations are checked when the alert is saved, an empty one is no hold
+	hold, _ := prommodel.ParseDuration(holdDuration)
+
+	return &Rule{
+		Alert:       alert.Description,
+		Expr:        expr,
+		For:         hold,
+		Labels:      labels,
+		Annotations: annotations,
+
+		selectorEmpty: !selected,
+	}, nil
+}
+
+// checkRule checks a generated rule the way Prometheus does when loading it,
+// along with the templates of its labels and annotations.
+func checkRule(rule Rule) error {
+	if rule.For < 0 {
+		return fmt.Errorf("negative hold duration %s", rule.For)
+	}
+
+	r := rulefmt.Rule{
+		Alert:       rule.Alert,
+		Expr:        rule.Expr,
+		For:         rule.For,
+		Labels:      rule.Labels,
+		Annotations: rule.Annotations,
+	}
+	if errs := r.Validate(); len(errs) != 0 {
+		return errs[0]
+	}
+
+	for name, value := range rule.Labels {
+		if err := util.CheckRuleTemplate(name, value); err != nil {
+			return err
+		}
+	}
+	for name, value := range rule.Annotations {
+		if err := util.CheckRuleTemplate(name, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkRuleGroups parses the rules file back and validates it like Prometheus.
+func checkRuleGroups(content []byte) error {
+	rgs := rulefmt.RuleGroups{}
+	if err := yaml.Unmarshal(content, &rgs); err != nil {
 		return err
 	}
+	if errs := rgs.Validate(); len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
 
-	c := config.GetConfig()
-	err = ioutil.WriteFile(c.PrometheusRule, ruleStr, 0777)
+// hostResourceExpr compiles a host resource rule into a node_exporter
+// expression, scoped by the environment and the host labels that the target
+// synchronizer puts on every scraped endpoint. It uses the metric names of
+// node_exporter 0.16 and later, which renamed them with their units. It also
+// reports whether the target selector of the alert selects any host.
+func hostResourceExpr(alert *model.Alert) (string, bool, error) {
+	rule := alert.HostResourceRule
+	matcher, selected, err := targetMatcher(alert, "host_id")
 	if err != nil {
-		logrus.Errorf("Error while writing the config to file: %s", err)
-		return err
+		return "", false, err
 	}
+	selector := fmt.Sprintf("job=\"%s\", environment_id=\"%s\", %s", JobNameNodeExporter, alert.Environment, matcher)
 
-	//reload prometheus configuration
-	go util.ReloadConfiguration(c.PrometheusURL)
+	var value string
+	switch rule.Metric {
+	case model.HostResourceCPU:
+		value = fmt.Sprintf("100 - avg by (environment_id, host_id) (irate(node_cpu_seconds_total{%s, mode=\"idle\"}[5m])) * 100", selector)
+	case model.HostResourceMemory:
+		value = fmt.Sprintf("(1 - node_memory_MemAvailable_bytes{%s} / node_memory_MemTotal_bytes{%s}) * 100", selector, selector)
+	case model.HostResourceDisk:
+		fs := selector + ", fstype!~\"tmpfs|rootfs\""
+		value = fmt.Sprintf("max by (environment_id, host_id) ((1 - node_filesystem_avail_bytes{%s} / node_filesystem_size_bytes{%s}) * 100)", fs, fs)
+	case model.HostResourceNetwork:
+		nic := selector + ", device!=\"lo\""
+		value = fmt.Sprintf("sum by (environment_id, host_id) (irate(node_network_receive_bytes_total{%s}[5m]) + irate(node_network_transmit_bytes_total{%s}[5m]))", nic, nic)
+	case model.HostResourceLoad:
+		value = fmt.Sprintf("node_load1{%s}", selector)
+	default:
+		return "", false, fmt.Errorf("unknown host resource metric %s", rule.Metric)
+	}
 
-	return nil
+	expr := fmt.Sprintf("(%s) %s %s", value, rule.Operator, strconv.FormatFloat(rule.Threshold, 'f', -1, 64))
+	return withTargetID(alert, expr, "host_id"), selected, nil
+}
+
+// seriesLabels are the labels the series of the rules of each target type
+// carry with values of their own. The health exporter series also carry the
+// host_id of the host they are scraped from, which is not the host of the
+// service or stack.
+var seriesLabels = map[string][]string{
+	model.TargetTypeHost:         {"host_id", "id", "environment_id"},
+	model.TargetTypeHostResource: {"host_id", "environment_id"},
+	model.TargetTypeService:      {"id", "environment_id"},
+	model.TargetTypeStack:        {"id", "environment_id"},
+	model.TargetTypeContainer:    {"host_id", "id", "name", "image", "environment_id"},
+}
+
+// HasSeriesLabel reports whether the firing series of the alert carry the
+// label, set by the rule or by the series. The labels of metric alerts depend
+// on their expression, so any is assumed.
+func HasSeriesLabel(alert *model.Alert, label string) bool {
+	switch label {
+	case "alert_id", "severity", "description", "target_type", "environment":
+		return true
+	case "target_id":
+		return alert.TargetID != "" || !alert.TargetSelector.IsEmpty()
+	}
+	if _, ok := alert.Labels[label]; ok {
+		return true
+	}
+
+	switch alert.TargetType {
+	case model.TargetTypeMetric:
+		return true
+	case model.TargetTypeContainer:
+		if strings.HasPrefix(label, "container_label_") {
+			return true
+		}
+	}
+	for _, l := range seriesLabels[alert.TargetType] {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// targetMatcher returns the label matcher selecting the targets of the alert:
+// its targetId, or the ids of the services or hosts its selector selects, and
+// whether it selects any. A selector selecting none gets a matcher matching
+// nothing, so that the rule is kept and fires once targets are selected.
+func targetMatcher(alert *model.Alert, label string) (string, bool, error) {
+	if alert.TargetSelector.IsEmpty() {
+		return fmt.Sprintf("%s=\"%s\"", label, alert.TargetID), true, nil
+	}
+
+	ids, err := selectedTargets(alert)
+	if err != nil {
+		return "", false, err
+	}
+	if len(ids) == 0 {
+		return fmt.Sprintf("%s!~\".*\"", label), false, nil
+	}
+
+	for i, id := range ids {
+		ids[i] = regexp.QuoteMeta(id)
+	}
+	return fmt.Sprintf("%s=~%s", label, strconv.Quote(strings.Join(ids, "|"))), true, nil
+}
+
+// selectedTargets returns the ids of the services or hosts the selector of
+// the alert selects.
+func selectedTargets(alert *model.Alert) ([]string, error) {
+	selector := alert.TargetSelector
+	if alert.TargetType == model.TargetTypeService {
+		return service.ListServiceIDs(alert.Environment, selector.StackID, selector.Labels)
+	}
+	return service.ListHostIDs(alert.Environment, selector.Labels)
+}
+
+// withTargetID copies the label identifying the target of a series into the
+// target_id label when the alert selects many targets, so that every firing
+// series tells which one triggered it.
+func withTargetID(alert *model.Alert, expr, label string) string {
+	if alert.TargetSelector.IsEmpty() {
+		return expr
+	}
+	return fmt.Sprintf("label_replace(%s, \"target_id\", \"$1\", \"%s\", \"(.*)\")", expr, label)
+}
+
+// containerExpr compiles a container rule into a cAdvisor expression. The
+// Rancher container and service ids are resolved to the container labels
+// cAdvisor exposes. OOM kills are counted by container_oom_events_total, which
+// needs cAdvisor 0.36 or later.
+func containerExpr(alert *model.Alert) (string, error) {
+	rule := alert.ContainerRule
+	selector := fmt.Sprintf("job=\"%s\", environment_id=\"%s\"", JobNameCadvisor, alert.Environment)
+
+	switch {
+	case alert.TargetID != "":
+		uuid, err := service.GetContainerUUID(alert.TargetID)
+		if err != nil {
+			return "", err
+		}
+		selector += fmt.Sprintf(", container_label_io_rancher_container_uuid=\"%s\"", uuid)
+	case rule.ServiceID != "":
+		name, err := service.GetStackServiceName(rule.ServiceID)
+		if err != nil {
+			return "", err
+		}
+		selector += fmt.Sprintf(", container_label_io_rancher_stack_service_name=\"%s\"", name)
+	default:
+		keys := []string{}
+		for k := range rule.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			selector += fmt.Sprintf(", %s=%s", containerLabelName(k), strconv.Quote(rule.Labels[k]))
+		}
+	}
+
+	window := rule.Window
+	if window == "" {
+		window = "1h"
+	}
+
+	var value string
+	switch rule.Metric {
+	case model.ContainerRestarts:
+		value = fmt.Sprintf("changes(container_start_time_seconds{%s}[%s])", selector, window)
+	case model.ContainerOOMKills:
+		value = fmt.Sprintf("increase(container_oom_events_total{%s}[%s])", selector, window)
+	case model.ContainerCPUThrottling:
+		value = fmt.Sprintf("rate(container_cpu_cfs_throttled_periods_total{%s}[5m]) / rate(container_cpu_cfs_periods_total{%s}[5m]) * 100", selector, selector)
+	case model.ContainerMemoryPercent:
+		value = fmt.Sprintf("container_memory_working_set_bytes{%s} / (container_spec_memory_limit_bytes{%s} > 0) * 100", selector, selector)
+	default:
+		return "", fmt.Errorf("unknown container metric %s", rule.Metric)
+	}
+
+	return fmt.Sprintf("(%s) %s %s", value, rule.Operator, strconv.FormatFloat(rule.Threshold, 'f', -1, 64)), nil
+}
+
+// containerLabelName returns the name cAdvisor gives to a docker label.
+func containerLabelName(label string) string {
+	name := []rune("container_label_" + label)
+	for i, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			name[i] = '_'
+		}
+	}
+	return string(name)
 }
 
 // RuleGroups is a set of rule groups that are typically exposed in a file.
@@ -157,4 +507,7 @@ type Rule struct {
 	For         prommodel.Duration `yaml:"for,omitempty"`
 	Labels      map[string]string  `yaml:"labels,omitempty"`
 	Annotations map[string]string  `yaml:"annotations,omitempty"`
+
+	//the target selector of the alert selected no target
+	selectorEmpty bool
 }
@@ -15,6 +15,9 @@ import (
 	"github.com/zionwu/monitoring-manager/util"
 )
 
+// maxConflictRetries is how many times a state update is retried on a version conflict
+const maxConflictRetries = 3
+
 type alertStateSynchronizer struct {
 }
 
@@ -41,54 +44,154 @@ func (s *alertStateSynchronizer) Run(stopc <-chan struct{}) error {
 							continue
 						}
 
-						state, a := util.GetState(alert, apiAlerts)
-						needUpdate := false
+						s.syncState(alert, apiAlerts)
+					}
+				}
+			}
 
-						//only take ation when the state is not the same
-						if state != alert.State {
+		case <-stopc:
+			return nil
+		}
+	}
 
-							//if the origin state is silenced, and current state is active, then need to remove the silence rule
-							if alert.State == model.AlertStateSuppressed && state == model.AlertStateEnabled {
-								util.RemoveSilence(alert)
-							}
+}
 
-							alert.State = state
-							needUpdate = true
-						}
+// syncState updates the state and time of the alert, retrying with the latest
+// version when the alert is modified by someone else in the meantime
+func (s *alertStateSynchronizer) syncState(alert *model.Alert, apiAlerts []*dispatch.APIAlert) {
+	id := alert.Id
+	for i := 0; ; i++ {
+		err := s.updateState(alert, apiAlerts)
+		if err == nil {
+			return
+		}
 
-						if state == model.AlertStateSuppressed || state == model.AlertStateActive {
-							if !alert.StartsAt.Equal(a.StartsAt) {
-								alert.StartsAt = a.StartsAt
-								needUpdate = true
-							}
-
-							if !alert.EndsAt.Equal(a.EndsAt) {
-								alert.EndsAt = a.EndsAt
-								needUpdate = true
-							}
-						} else {
-							alert.StartsAt = time.Time{}
-							alert.EndsAt = time.Time{}
-						}
+		if err != service.ErrConflict || i >= maxConflictRetries {
+			logrus.Errorf("Error occurred while syn alert state and time: %v", err)
+			return
+		}
 
-						if needUpdate {
+		alert, err = service.GetAlert(id)
+		if err != nil {
+			logrus.Errorf("Error while getting alert %s: %v", id, err)
+			return
+		}
+		if alert.State == model.AlertStateDisabled {
+			return
+		}
+	}
+}
 
-							err := service.UpdateAlert(alert)
+func (s *alertStateSynchronizer) updateState(alert *model.Alert, apiAlerts []*dispatch.APIAlert) error {
+	state, instances := util.GetState(alert, apiAlerts)
+	needUpdate := false
+	fromState := alert.State
 
-							if err != nil {
-								logrus.Errorf("Error occurred while syn alert state and time: %v", err)
-							}
-						}
+	//only take ation when the state is not the same
+	if state != alert.State {
 
-					}
-				}
+		alert.State = state
+		needUpdate = true
+	}
+
+	pending := 0
+	if service.TransitionEvent(fromState, state) != "" {
+		pending = 1
+	}
+	flapping, err := service.IsAlertFlapping(alert, pending)
+	if err != nil {
+		logrus.Errorf("Error while checking if alert %s is flapping: %v", alert.Id, err)
+	} else if flapping != alert.Flapping {
+		alert.Flapping = flapping
+		needUpdate = true
+	}
+
+	//the alert fires from its first instance until its last one ends
+	var startsAt, endsAt time.Time
+	if state == model.AlertStateSuppressed || state == model.AlertStateActive {
+		startsAt, endsAt = instances[0].StartsAt, instances[0].EndsAt
+		for _, instance := range instances {
+			if instance.EndsAt.After(endsAt) {
+				endsAt = instance.EndsAt
 			}
+		}
+	}
 
-		case <-stopc:
-			return nil
+	//forget the silence once it expired or the alert resolved, an expired
+	//silence lets Alertmanager report the alert as active again
+	if alert.Silence.SilenceID != "" {
+		expired := !alert.Silence.EndsAt.After(time.Now())
+		if !expired && state == model.AlertStateEnabled {
+			//the silence of a resolved alert must not hold back its next firing
+			if err := util.DeleteSilence(alert.Silence.SilenceID); err != nil {
+				logrus.Errorf("Error while deleting silence of alert %s: %v", alert.Id, err)
+			} else {
+				expired = true
+			}
+		}
+		if expired {
+			alert.Silence = model.AlertSilenceSpec{}
+			needUpdate = true
+		}
+	}
+
+	if needUpdate {
+		if err := service.UpdateAlert(alert); err != nil {
+			return err
+		}
+	}
+
+	lastStartsAt := s.recordInstances(alert, startsAt, endsAt, instances)
+
+	if fromState != alert.State {
+		//a resolved alert fired from the start of its last instances
+		if startsAt.IsZero() {
+			startsAt = lastStartsAt
+		}
+		if err := service.RecordAlertTransition(alert, fromState, startsAt); err != nil {
+			logrus.Errorf("Error while recording state transition of alert %s: %v", alert.Id, err)
 		}
 	}
 
+	return nil
+}
+
+// recordInstances records the firing instances and times of the alert on its
+// status, and returns the time it fired from until then. They change with
+// every evaluation of the rule, so they are kept apart from the alert to
+// leave its version alone.
+func (s *alertStateSynchronizer) recordInstances(alert *model.Alert, startsAt, endsAt time.Time, instances []*model.AlertInstance) time.Time {
+	var lastStartsAt time.Time
+	err := service.UpdateAlertStatus(alert, func(status *model.AlertStatus) bool {
+		lastStartsAt = status.StartsAt
+		if status.StartsAt.Equal(startsAt) && status.EndsAt.Equal(endsAt) && sameInstances(status.Instances, instances) {
+			return false
+		}
+		status.StartsAt = startsAt
+		status.EndsAt = endsAt
+		status.Instances = instances
+		return true
+	})
+	if err != nil {
+		logrus.Errorf("Error while recording the status of alert %s: %v", alert.Id, err)
+	}
+
+	return lastStartsAt
+}
+
+// sameInstances reports whether both lists hold the same instances in the
+// same state
+func sameInstances(a, b []*model.AlertInstance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Fingerprint != b[i].Fingerprint || a[i].Silenced != b[i].Silenced ||
+			!a[i].StartsAt.Equal(b[i].StartsAt) || !a[i].EndsAt.Equal(b[i].EndsAt) {
+			return false
+		}
+	}
+	return true
 }
 
 func getActiveAlertListFromAlertManager() ([]*dispatch.APIAlert, error) {
@@ -16,6 +16,10 @@ func NewAlertRouteSynchronizer(alertChan <-chan struct{}) Synchronizer {
 	return &alertRouteSynchronizer{alertChan: alertChan}
 }
 
+func NewMaintenanceSynchronizer() Synchronizer {
+	return &maintenanceSynchronizer{}
+}
+
 func NewPrometheusRuleSynchronizer(promChan <-chan struct{}) Synchronizer {
 	return &prometheusRuleSynchronizer{promChan: promChan}
 }
@@ -111,28 +111,26 @@ func (s *prometheusTargetSynchronizer) Run(stopc <-chan struct{}) error {
 				}
 
 				for idx, scrape := range expectedScrapes {
-					var (
-						targets      []model.LabelSet
-						staticConfig *promconfig.TargetGroup
-					)
-
-					// each host will become a scraped endpoint
+					// each host will become a scraped endpoint, labelled with
+					// the host so that rules can be scoped to it
 					for _, host := range hosts.Data {
-						targets = append(targets, model.LabelSet{
-							model.AddressLabel: model.LabelValue(fmt.Sprintf("%s:%s", host.AgentIpAddress, expectedScrapePorts[idx])),
-						})
-					}
-
-					staticConfig = &promconfig.TargetGroup{
-						Targets: targets,
-						Labels: map[model.LabelName]model.LabelValue{
-							"environment_id":   model.LabelValue(project.Id),
-							"environment_name": model.LabelValue(project.Name),
-						},
-						Source: project.Id,
+						staticConfig := &promconfig.TargetGroup{
+							Targets: []model.LabelSet{
+								{
+									model.AddressLabel: model.LabelValue(fmt.Sprintf("%s:%s", host.AgentIpAddress, expectedScrapePorts[idx])),
+								},
+							},
+							Labels: map[model.LabelName]model.LabelValue{
+								"environment_id":   model.LabelValue(project.Id),
+								"environment_name": model.LabelValue(project.Name),
+								"host_id":          model.LabelValue(host.Id),
+								"host_name":        model.LabelValue(host.Hostname),
+							},
+							Source: host.Id,
+						}
+
+						scrape.ServiceDiscoveryConfig.StaticConfigs = append(scrape.ServiceDiscoveryConfig.StaticConfigs, staticConfig)
 					}
-
-					scrape.ServiceDiscoveryConfig.StaticConfigs = append(scrape.ServiceDiscoveryConfig.StaticConfigs, staticConfig)
 				}
 
 			}
@@ -0,0 +1,81 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	//2018-03-14 is a wednesday
+	from := time.Date(2018, 3, 14, 10, 30, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2018, 3, 14, 10, 31, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2018, 3, 15, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, 3, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 0-6 1,15 * *", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2018, 3, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 4 *", time.Time{}},
+		//sunday is both 0 and 7
+		{"0 0 * * 0", time.Date(2018, 3, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, 3, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)},
+		//a restricted day of month and day of week match either
+		{"0 0 1 * 6", time.Date(2018, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 16 * 1", time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC)},
+		//a field starting with * is not restricted, both must match
+		{"0 0 * * 5", time.Date(2018, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 */2 * 1", time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-31 * 6", time.Date(2018, 3, 15, 0, 0, 0, 0, time.UTC)},
+	} {
+		schedule, err := ParseCron(test.spec)
+		if err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", test.spec, err)
+			continue
+		}
+		if got := schedule.Next(from); !got.Equal(test.want) {
+			t.Errorf("Next of %q = %v, want %v", test.spec, got, test.want)
+		}
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*60*60)
+	schedule, err := ParseCron("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2018, 3, 14, 10, 30, 0, 0, time.UTC)
+	want := time.Date(2018, 3, 15, 2, 0, 0, 0, loc)
+	if got := schedule.Next(from.In(loc)); !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
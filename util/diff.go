@@ -0,0 +1,212 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines around the changes of a hunk.
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Diff returns the unified diff from a to b, empty when they are equal.
+func Diff(fromName, toName string, a, b []byte) string {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	changes := []int{}
+	for i, l := range lines {
+		if l.op != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	//number of lines of a and b before each diff line
+	aLine := make([]int, len(lines)+1)
+	bLine := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if l.op != '+' {
+			aLine[i+1]++
+		}
+		if l.op != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+	for c := 0; c < len(changes); {
+		//changes closer than twice the context share a hunk
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContext {
+			last++
+		}
+		start := changes[c] - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := changes[last] + diffContext + 1
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(aLine[start], aLine[end]), hunkRange(bLine[start], bLine[end]))
+		for _, l := range lines[start:end] {
+			buf.WriteByte(l.op)
+			buf.WriteString(l.text)
+			buf.WriteByte('\n')
+		}
+		c = last + 1
+	}
+
+	return buf.String()
+}
+
+// hunkRange formats the lines from start to end of a hunk, where an empty
+// range starts at the line before it.
+func hunkRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// diffLines returns the lines of a and b along a shortest edit script from a
+// to b. It is found with the linear space variant of the Myers algorithm, in
+// O((N+M)D) time for N and M lines and D differences.
+func diffLines(a, b []string) []diffLine {
+	lines := make([]diffLine, 0, len(a)+len(b))
+	return appendDiff(lines, a, b)
+}
+
+func appendDiff(lines []diffLine, a, b []string) []diffLine {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		lines = append(lines, diffLine{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		for _, l := range b {
+			lines = append(lines, diffLine{'+', l})
+		}
+	case len(b) == 0:
+		for _, l := range a {
+			lines = append(lines, diffLine{'-', l})
+		}
+	default:
+		d, x, y, u, v := middleSnake(a, b)
+		if d > 1 {
+			lines = appendDiff(lines, a[:x], b[:y])
+			for _, l := range a[x:u] {
+				lines = append(lines, diffLine{' ', l})
+			}
+			lines = appendDiff(lines, a[u:], b[v:])
+			break
+		}
+
+		//a single line added or removed, the others are common
+		for len(a) > 0 || len(b) > 0 {
+			switch {
+			case len(a) > 0 && len(b) > 0 && a[0] == b[0]:
+				lines = append(lines, diffLine{' ', a[0]})
+				a, b = a[1:], b[1:]
+			case len(b) > len(a):
+				lines = append(lines, diffLine{'+', b[0]})
+				b = b[1:]
+			default:
+				lines = append(lines, diffLine{'-', a[0]})
+				a = a[1:]
+			}
+		}
+	}
+
+	for _, l := range common {
+		lines = append(lines, diffLine{' ', l})
+	}
+	return lines
+}
+
+// middleSnake returns the number of differences d between a and b, and the
+// snake from (x, y) to (u, v) in the middle of a shortest edit script, where
+// the forward and the reverse searches meet. Both halves around the snake
+// have at most half the differences.
+func middleSnake(a, b []string) (int, int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+
+	//furthest x reached on each diagonal k = x - y, forward from the start
+	//and in reverse from the end, counted from the end
+	off := max + 1
+	vf := make([]int, 2*max+3)
+	vr := make([]int, 2*max+3)
+
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && vf[off+k-1] < vf[off+k+1] {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+
+			//the reverse search has gone d-1 steps
+			if r := delta - k; odd && r >= -(d-1) && r <= d-1 && x+vr[off+r] >= n {
+				return 2*d - 1, x0, y0, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && vr[off+k-1] < vr[off+k+1] {
+				x = vr[off+k+1]
+			} else {
+				x = vr[off+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vr[off+k] = x
+
+			if f := delta - k; !odd && f >= -d && f <= d && x+vf[off+f] >= n {
+				return 2 * d, n - x, m - y, n - x0, m - y0
+			}
+		}
+	}
+
+	//not reached, the searches meet within max steps
+	return n + m, n, 0, n, 0
+}
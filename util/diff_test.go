@@ -0,0 +1,129 @@
+package util
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "changed line",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\nb\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed file",
+			a:    "a\n",
+			b:    "",
+			want: "--- a\n+++ b\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	} {
+		if got := Diff("a", "b", []byte(test.a), []byte(test.b)); got != test.want {
+			t.Errorf("%s: Diff = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiffLinesShortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(12))
+		for i := range lines {
+			lines[i] = string('a' + rune(r.Intn(3)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(), randomLines()
+		lines := diffLines(a, b)
+
+		var gotA, gotB []string
+		edits := 0
+		for _, l := range lines {
+			if l.op != '+' {
+				gotA = append(gotA, l.text)
+			}
+			if l.op != '-' {
+				gotB = append(gotB, l.text)
+			}
+			if l.op != ' ' {
+				edits++
+			}
+		}
+		if strings.Join(gotA, ",") != strings.Join(a, ",") || strings.Join(gotB, ",") != strings.Join(b, ",") {
+			t.Fatalf("diff of %q and %q does not give them back: %v", a, b, lines)
+		}
+		if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+			t.Fatalf("diff of %q and %q has %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+func TestDiffLarge(t *testing.T) {
+	a := make([]string, 100000)
+	for i := range a {
+		a[i] = strconv.Itoa(i)
+	}
+	b := append([]string{}, a...)
+	b[10] = "changed"
+	b = append(b[:50000], b[50100:]...)
+
+	lines := diffLines(a, b)
+	edits := 0
+	for _, l := range lines {
+		if l.op != ' ' {
+			edits++
+		}
+	}
+	if edits != 102 {
+		t.Errorf("diff has %d edits, want 102", edits)
+	}
+}
+
+// lcsLength is the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
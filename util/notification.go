@@ -0,0 +1,214 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	tmpltext "text/template"
+	"time"
+
+	amtemplate "github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/zionwu/monitoring-manager/config"
+	"github.com/zionwu/monitoring-manager/model"
+)
+
+// Fields of a notification template, each defined as a template of its own
+// in the templates file loaded by Alertmanager.
+const (
+	NotificationEmailSubject = "emailSubject"
+	NotificationEmailText    = "emailText"
+	NotificationEmailHTML    = "emailHtml"
+	NotificationTitle        = "title"
+	NotificationText         = "text"
+
+	NotificationEmailResolvedSubject = "emailResolvedSubject"
+	NotificationEmailResolvedText    = "emailResolvedText"
+	NotificationEmailResolvedHTML    = "emailResolvedHtml"
+)
+
+type notificationField struct {
+	name     string
+	text     string
+	resolved bool
+}
+
+func notificationFields(t *model.NotificationTemplate) []notificationField {
+	fields := []notificationField{}
+	for _, f := range []notificationField{
+		{NotificationEmailSubject, t.EmailSubject, false},
+		{NotificationEmailText, t.EmailText, false},
+		{NotificationEmailHTML, t.EmailHTML, false},
+		{NotificationEmailResolvedSubject, t.EmailResolvedSubject, true},
+		{NotificationEmailResolvedText, t.EmailResolvedText, true},
+		{NotificationEmailResolvedHTML, t.EmailResolvedHTML, true},
+		{NotificationTitle, t.Title, false},
+		{NotificationText, t.Text, false},
+	} {
+		if f.text != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// NotificationTemplateName is the name a field of the template with id is
+// defined under.
+func NotificationTemplateName(id, field string) string {
+	return id + "." + field
+}
+
+// NotificationTemplateRef returns the template text of a receiver setting
+// that expands a field of the template with id.
+func NotificationTemplateRef(id, field string) string {
+	return fmt.Sprintf("{{ template %q . }}", NotificationTemplateName(id, field))
+}
+
+// NotificationResolvedRef returns the template text of a receiver setting
+// that expands resolved for the notifications of resolved alerts and firing
+// for the others.
+func NotificationResolvedRef(firing, resolved string) string {
+	return fmt.Sprintf(`{{ if eq .Status "resolved" }}%s{{ else }}%s{{ end }}`, resolved, firing)
+}
+
+// NotificationTemplateDefs returns the content of the templates file that
+// defines the fields of the templates.
+func NotificationTemplateDefs(templates []*model.NotificationTemplate) string {
+	var buf bytes.Buffer
+	for _, t := range templates {
+		for _, f := range notificationFields(t) {
+			fmt.Fprintf(&buf, "{{ define %q }}%s{{ end }}\n", NotificationTemplateName(t.Id, f.name), f.text)
+		}
+	}
+	return buf.String()
+}
+
+// CheckNotificationTemplate checks that every field of the template parses
+// and executes like Alertmanager would.
+func CheckNotificationTemplate(t *model.NotificationTemplate) error {
+	_, err := RenderNotificationTemplate(t)
+	return err
+}
+
+// RenderNotificationTemplate renders the template against a sample alert.
+func RenderNotificationTemplate(t *model.NotificationTemplate) (*model.NotificationPreview, error) {
+	//the fields are defined inside a template, so they can not define their own
+	for _, f := range notificationFields(t) {
+		parsed, err := tmpltext.New(f.name).Funcs(tmpltext.FuncMap(amtemplate.DefaultFuncs)).Parse(f.text)
+		if err != nil {
+			return nil, err
+		}
+		if len(parsed.Templates()) > 1 {
+			return nil, fmt.Errorf("%s can not define templates", f.name)
+		}
+	}
+
+	preview := *t
+	preview.Id = "preview"
+	tmpl, err := LoadNotificationTemplates([]*model.NotificationTemplate{&preview})
+	if err != nil {
+		return nil, err
+	}
+
+	groupLabels := prommodel.LabelSet{"alert_id": "sample"}
+	firing := tmpl.Data("preview", groupLabels, sampleAlert(t.Environment, false))
+	resolved := tmpl.Data("preview", groupLabels, sampleAlert(t.Environment, true))
+
+	result := &model.NotificationPreview{}
+	for _, f := range notificationFields(t) {
+		ref := NotificationTemplateRef(preview.Id, f.name)
+		data := firing
+		if f.resolved {
+			data = resolved
+		}
+
+		var out string
+		if f.name == NotificationEmailHTML || f.name == NotificationEmailResolvedHTML {
+			out, err = tmpl.ExecuteHTMLString(ref, data)
+		} else {
+			out, err = tmpl.ExecuteTextString(ref, data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while executing %s: %v", f.name, err)
+		}
+
+		switch f.name {
+		case NotificationEmailSubject:
+			result.EmailSubject = out
+		case NotificationEmailText:
+			result.EmailText = out
+		case NotificationEmailHTML:
+			result.EmailHTML = out
+		case NotificationEmailResolvedSubject:
+			result.EmailResolvedSubject = out
+		case NotificationEmailResolvedText:
+			result.EmailResolvedText = out
+		case NotificationEmailResolvedHTML:
+			result.EmailResolvedHTML = out
+		case NotificationTitle:
+			result.Title = out
+		case NotificationText:
+			result.Text = out
+		}
+	}
+
+	return result, nil
+}
+
+// LoadNotificationTemplates loads the fields of the templates the way
+// Alertmanager does, along with its default templates.
+func LoadNotificationTemplates(templates []*model.NotificationTemplate) (*amtemplate.Template, error) {
+	f, err := ioutil.TempFile("", "notification-template")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(NotificationTemplateDefs(templates))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := amtemplate.FromGlobs(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExternalURL, err = url.Parse(config.GetConfig().AlertManagerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return tmpl, nil
+}
+
+// sampleAlert is an alert with the labels and annotations of the alerts
+// generated by the manager.
+func sampleAlert(environment string, resolved bool) *types.Alert {
+	alert := &types.Alert{
+		Alert: prommodel.Alert{
+			Labels: prommodel.LabelSet{
+				"alert_id":    "sample",
+				"environment": prommodel.LabelValue(environment),
+				"description": "Sample alert",
+				"severity":    "critical",
+				"target_type": model.TargetTypeHost,
+				"target_id":   "1h1",
+				"host_id":     "1h1",
+			},
+			Annotations: prommodel.LabelSet{
+				"summary":     "Host 1h1 is down",
+				"runbook_url": "https://runbooks.example.com/host-down",
+			},
+			StartsAt: time.Now().Add(-5 * time.Minute),
+		},
+	}
+	if resolved {
+		alert.EndsAt = time.Now().Add(-time.Minute)
+	}
+	return alert
+}
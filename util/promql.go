@@ -0,0 +1,84 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	prommodel "github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/zionwu/monitoring-manager/config"
+)
+
+// ExprError is the error of an expression Prometheus can not parse.
+type ExprError struct {
+	Expr string
+	Msg  string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("invalid expression %q: %s", e.Expr, e.Msg)
+}
+
+var promClient = &http.Client{Timeout: 10 * time.Second}
+
+// CheckExpr checks that expr is a valid PromQL expression, parsing it like
+// Prometheus does when it loads the rules. It returns an *ExprError when the
+// expression does not parse.
+func CheckExpr(expr string) error {
+	if _, err := promql.ParseExpr(expr); err != nil {
+		return &ExprError{Expr: expr, Msg: err.Error()}
+	}
+	return nil
+}
+
+// QueryRange evaluates expr at every step from start to end through the range
+// query API of Prometheus. It returns an *ExprError when the expression does
+// not parse.
+func QueryRange(expr string, start, end time.Time, step time.Duration) (prommodel.Matrix, error) {
+	vals := url.Values{}
+	vals.Set("query", expr)
+	vals.Set("start", strconv.FormatInt(start.Unix(), 10))
+	vals.Set("end", strconv.FormatInt(end.Unix(), 10))
+	vals.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	resp, err := promClient.Get(config.GetConfig().PrometheusURL + "/api/v1/query_range?" + vals.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	res, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType string           `json:"resultType"`
+			Result     prommodel.Matrix `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return nil, fmt.Errorf("unexpected response from prometheus with status %d: %s", resp.StatusCode, string(res))
+	}
+
+	if result.Status == "error" {
+		if result.ErrorType == "bad_data" {
+			return nil, &ExprError{Expr: expr, Msg: result.Error}
+		}
+		return nil, fmt.Errorf("error while querying prometheus: %s", result.Error)
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %s of expression %q", result.Data.ResultType, expr)
+	}
+
+	return result.Data.Result, nil
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ConfigFile is a file of the configuration of a server.
+type ConfigFile struct {
+	Path string
+	Data []byte
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames it
+// to path, so that the server never reads a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
+
+// ApplyConfiguration writes the files and reloads the server at url. When the
+// server rejects the new configuration, the previous files are restored and
+// the server reloaded again so that it keeps running them, and the error is
+// returned. A server that can not be reached loads the new files when it
+// starts.
+func ApplyConfiguration(url string, files ...ConfigFile) error {
+	previous := make([]*ConfigFile, len(files))
+	for i, f := range files {
+		data, err := ioutil.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		previous[i] = &ConfigFile{Path: f.Path, Data: data}
+	}
+
+	for _, f := range files {
+		if err := WriteFileAtomic(f.Path, f.Data, 0777); err != nil {
+			restoreFiles(files, previous)
+			return err
+		}
+	}
+
+	err := ReloadConfiguration(url)
+	if _, rejected := err.(*ReloadError); !rejected {
+		return err
+	}
+
+	logrus.Errorf("Restoring the previous configuration of %s: %v", url, err)
+	restoreFiles(files, previous)
+	if rerr := ReloadConfiguration(url); rerr != nil {
+		logrus.Errorf("Error while reloading the previous configuration of %s: %v", url, rerr)
+	}
+
+	return err
+}
+
+// restoreFiles puts back the previous content of the files, removing those
+// that did not exist.
+func restoreFiles(files []ConfigFile, previous []*ConfigFile) {
+	for i, f := range files {
+		var err error
+		if previous[i] == nil {
+			err = os.Remove(f.Path)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		} else {
+			err = WriteFileAtomic(f.Path, previous[i].Data, 0777)
+		}
+		if err != nil {
+			logrus.Errorf("Error while restoring %s: %v", f.Path, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"text/template"
+)
+
+// ruleTemplateFuncs stubs the functions Prometheus 2.0.0, the vendored version,
+// provides to the templates of alerting rules, so that the templates can be
+// parsed here. The template package of Prometheus is not vendored, the list
+// follows its v2.0.0 func map.
+var ruleTemplateFuncs = template.FuncMap{
+	"args":              func(...interface{}) interface{} { return nil },
+	"externalURL":       func() string { return "" },
+	"first":             func(interface{}) interface{} { return nil },
+	"graphLink":         func(string) string { return "" },
+	"humanize":          func(interface{}) string { return "" },
+	"humanize1024":      func(interface{}) string { return "" },
+	"humanizeDuration":  func(interface{}) string { return "" },
+	"humanizeTimestamp": func(interface{}) string { return "" },
+	"label":             func(string, interface{}) string { return "" },
+	"match":             func(string, string) bool { return false },
+	"pathPrefix":        func() string { return "" },
+	"query":             func(string) interface{} { return nil },
+	"reReplaceAll":      func(string, string, string) string { return "" },
+	"safeHtml":          func(string) string { return "" },
+	"sortByLabel":       func(string, interface{}) interface{} { return nil },
+	"strvalue":          func(interface{}) string { return "" },
+	"tableLink":         func(string) string { return "" },
+	"title":             func(string) string { return "" },
+	"toLower":           func(string) string { return "" },
+	"toUpper":           func(string) string { return "" },
+	"value":             func(interface{}) interface{} { return nil },
+}
+
+// CheckRuleTemplate checks that text is a valid template for the labels and
+// annotations of a Prometheus alerting rule, which may use $labels and $value.
+func CheckRuleTemplate(name, text string) error {
+	defs := "{{$labels := .Labels}}{{$value := .Value}}"
+	_, err := template.New(name).Funcs(ruleTemplateFuncs).Option("missingkey=zero").Parse(defs + text)
+	return err
+}
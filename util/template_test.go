@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestCheckRuleTemplate(t *testing.T) {
+	for _, test := range []struct {
+		text  string
+		valid bool
+	}{
+		{"{{ $labels.instance }} is down", true},
+		{"{{ $value | humanize }}", true},
+		{`{{ with query "up" }}{{ . | first | value }}{{ end }}`, true},
+		{`{{ "up" | tableLink }}`, true},
+		{`{{ args 1 2 }}`, true},
+		//added after Prometheus 2.0.0
+		{"{{ $value | humanizePercentage }}", false},
+		{"{{ $labels.instance ", false},
+	} {
+		err := CheckRuleTemplate("summary", test.text)
+		if (err == nil) != test.valid {
+			t.Errorf("CheckRuleTemplate(%q) = %v, want valid %v", test.text, err, test.valid)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -16,6 +18,18 @@ import (
 	"github.com/zionwu/monitoring-manager/model"
 )
 
+// ReloadError is returned when a server rejects the reload of its
+// configuration, as opposed to not being reachable.
+type ReloadError struct {
+	URL    string
+	Status int
+	Msg    string
+}
+
+func (e *ReloadError) Error() string {
+	return fmt.Sprintf("reloading %s failed with status %d: %s", e.URL, e.Status, e.Msg)
+}
+
 func ReloadConfiguration(url string) error {
 	//TODO: what is the wait time
 	time.Sleep(10 * time.Second)
@@ -26,17 +40,20 @@ func ReloadConfiguration(url string) error {
 	}
 	defer resp.Body.Close()
 
-	_, err = ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
 
+	if resp.StatusCode/100 != 2 {
+		return &ReloadError{URL: url, Status: resp.StatusCode, Msg: strings.TrimSpace(string(body))}
+	}
+
 	return nil
 }
 
-func AddSilence(alert *model.Alert) error {
-
-	url := config.GetConfig().AlertManagerURL
+// AddSilence silences the alert, filling in the id of the created silence.
+func AddSilence(alert *model.Alert, silence *model.AlertSilenceSpec) error {
 
 	matchers := []*prommodel.Matcher{}
 	m1 := &prommodel.Matcher{
@@ -53,22 +70,50 @@ func AddSilence(alert *model.Alert) error {
 	}
 	matchers = append(matchers, m2)
 
-	now := time.Now()
-	endsAt := now.AddDate(100, 0, 0)
+	return CreateSilence(matchers, silence)
+}
+
+// AddInstanceSilence silences a single instance of an alert, matching all
+// the labels of the instance.
+func AddInstanceSilence(instance *model.AlertInstance, silence *model.AlertSilenceSpec) error {
+
+	names := []string{}
+	for name := range instance.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	matchers := []*prommodel.Matcher{}
+	for _, name := range names {
+		matchers = append(matchers, &prommodel.Matcher{
+			Name:    prommodel.LabelName(name),
+			Value:   instance.Labels[name],
+			IsRegex: false,
+		})
+	}
+
+	return CreateSilence(matchers, silence)
+}
+
+// CreateSilence creates a silence for the matchers, filling in its id.
+func CreateSilence(matchers []*prommodel.Matcher, spec *model.AlertSilenceSpec) error {
+
+	url := config.GetConfig().AlertManagerURL
+
 	silence := prommodel.Silence{
 		Matchers:  matchers,
-		StartsAt:  now,
-		EndsAt:    endsAt,
-		CreatedAt: now,
-		CreatedBy: "rancherlabs",
-		Comment:   "silence",
+		StartsAt:  spec.StartsAt,
+		EndsAt:    spec.EndsAt,
+		CreatedAt: time.Now(),
+		CreatedBy: spec.CreatedBy,
+		Comment:   spec.Comment,
 	}
 
 	silenceData, err := json.Marshal(silence)
 	if err != nil {
 		return err
 	}
-	logrus.Debugf(string(silenceData))
+	logrus.Debugf("silence: %s", silenceData)
 
 	resp, err := http.Post(url+"/api/v1/silences", "application/json", bytes.NewBuffer(silenceData))
 	if err != nil {
@@ -82,25 +127,34 @@ func AddSilence(alert *model.Alert) error {
 	}
 	logrus.Debugf("add silence: %s", string(res))
 
+	result := struct {
+		Data struct {
+			SilenceID string `json:"silenceId"`
+		} `json:"data"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}{}
+	if err := json.Unmarshal(res, &result); err != nil {
+		return err
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("Failed to add silence: %s", result.Error)
+	}
+	spec.SilenceID = result.Data.SilenceID
+
 	return nil
 
 }
 
-func RemoveSilence(alert *model.Alert) error {
+// DeleteSilence expires the silence with the id.
+func DeleteSilence(id string) error {
 
 	url := config.GetConfig().AlertManagerURL
 
-	res := struct {
-		Data   []*types.Silence `json:"data"`
-		Status string           `json:"status"`
-	}{}
-
-	req, err := http.NewRequest(http.MethodGet, url+"/api/v1/silences", nil)
+	req, err := http.NewRequest(http.MethodDelete, url+"/api/v1/silence/"+id, nil)
 	if err != nil {
 		return err
 	}
-	q := req.URL.Query()
-	q.Add("filter", fmt.Sprintf("{%s, %s}", "alert_id="+alert.Id, "environment="+alert.Environment))
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -109,54 +163,92 @@ func RemoveSilence(alert *model.Alert) error {
 	}
 	defer resp.Body.Close()
 
-	requestBytes, err := ioutil.ReadAll(resp.Body)
+	res, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
+	logrus.Debugf("delete silence: %s", string(res))
 
-	if err := json.Unmarshal(requestBytes, &res); err != nil {
-		return err
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to delete silence %s: %s", id, string(res))
 	}
 
-	if res.Status != "success" {
-		return fmt.Errorf("Failed to get silence rules for alert")
-	}
+	return nil
+}
 
-	for _, s := range res.Data {
-		if s.Status.State == types.SilenceStateActive {
-			delReq, err := http.NewRequest(http.MethodDelete, url+"/api/v1/silence/"+s.ID, nil)
-			if err != nil {
-				return err
-			}
+// ListSilences returns the silences of Alertmanager, the expired ones
+// included.
+func ListSilences() ([]*types.Silence, error) {
 
-			delResp, err := client.Do(delReq)
-			defer delResp.Body.Close()
+	url := config.GetConfig().AlertManagerURL
 
-			res, err := ioutil.ReadAll(delResp.Body)
-			if err != nil {
-				return err
-			}
-			logrus.Debugf("delete silence: %s", string(res))
+	res := struct {
+		Data   []*types.Silence `json:"data"`
+		Status string           `json:"status"`
+	}{}
 
-		}
+	resp, err := http.Get(url + "/api/v1/silences")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
+	requestBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := json.Unmarshal(requestBytes, &res); err != nil {
+		return nil, err
+	}
+
+	if res.Status != "success" {
+		return nil, fmt.Errorf("Failed to get silences: %s", string(requestBytes))
+	}
+
+	return res.Data, nil
 }
 
-func GetState(alert *model.Alert, apiAlerts []*dispatch.APIAlert) (string, *dispatch.APIAlert) {
+// GetState returns the state of the alert along with all its firing
+// instances, oldest first. The alert is active as long as one instance is
+// not suppressed.
+func GetState(alert *model.Alert, apiAlerts []*dispatch.APIAlert) (string, []*model.AlertInstance) {
 
+	instances := []*model.AlertInstance{}
+	state := model.AlertStateEnabled
 	for _, a := range apiAlerts {
 		if string(a.Labels["alert_id"]) == alert.Id && string(a.Labels["environment"]) == alert.Environment {
-			if a.Status.State == types.AlertStateSuppressed {
-				return model.AlertStateSuppressed, a
-			} else {
-				return model.AlertStateActive, a
+			labels := map[string]string{}
+			for k, v := range a.Labels {
+				labels[string(k)] = string(v)
+			}
+
+			instance := &model.AlertInstance{
+				AlertID:     alert.Id,
+				Fingerprint: a.Fingerprint,
+				Labels:      labels,
+				StartsAt:    a.StartsAt,
+				EndsAt:      a.EndsAt,
+				Silenced:    a.Status.State == types.AlertStateSuppressed,
+				SilencedBy:  a.Status.SilencedBy,
+			}
+			instances = append(instances, instance)
+
+			if !instance.Silenced {
+				state = model.AlertStateActive
+			} else if state == model.AlertStateEnabled {
+				state = model.AlertStateSuppressed
 			}
 		}
 	}
 
-	return model.AlertStateEnabled, nil
+	sort.Slice(instances, func(i, j int) bool {
+		if !instances[i].StartsAt.Equal(instances[j].StartsAt) {
+			return instances[i].StartsAt.Before(instances[j].StartsAt)
+		}
+		return instances[i].Fingerprint < instances[j].Fingerprint
+	})
+
+	return state, instances
 
 }